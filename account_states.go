@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+const (
+	AccountActive       = "active"
+	AccountSuspended    = "suspended"
+	AccountShadowBanned = "shadow_banned"
+	AccountDeactivated  = "deactivated"
+)
+
+var accountStates = map[string]bool{
+	AccountActive:       true,
+	AccountSuspended:    true,
+	AccountShadowBanned: true,
+	AccountDeactivated:  true,
+}
+
+var (
+	errAccountSuspended   = errors.New("account is suspended")
+	errAccountDeactivated = errors.New("account is deactivated")
+)
+
+// accountState returns user's state as of now. A suspension whose expiry
+// has passed counts as active.
+func accountState(user database.User) string {
+	if user.AccountState == AccountSuspended && !isSuspended(user) {
+		return AccountActive
+	}
+	return user.AccountState
+}
+
+// isSuspended reports whether user is serving a suspension right now.
+func isSuspended(user database.User) bool {
+	return user.AccountState == AccountSuspended &&
+		user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now())
+}
+
+// checkAccountActive returns why user may not sign in or post, or nil.
+// Shadow-banned accounts pass: they aren't meant to notice.
+func checkAccountActive(user database.User) error {
+	switch accountState(user) {
+	case AccountSuspended:
+		return fmt.Errorf("%w until %s", errAccountSuspended, user.SuspendedUntil.Time.Format(time.RFC3339))
+	case AccountDeactivated:
+		return errAccountDeactivated
+	}
+	return nil
+}
+
+// respondWithAccountError explains a checkAccountActive failure, with the
+// expiry as its own field so clients needn't parse the message.
+func respondWithAccountError(w http.ResponseWriter, user database.User, err error) {
+	type errorResponse struct {
+		Error          string     `json:"error"`
+		AccountState   string     `json:"account_state"`
+		SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	}
+	resp := errorResponse{Error: err.Error(), AccountState: accountState(user)}
+	if errors.Is(err, errAccountSuspended) {
+		resp.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	respondWithJSON(w, http.StatusForbidden, resp)
+}
+
+// setAccountState moves userID to state. Suspending or deactivating an
+// account also revokes its refresh tokens so it can't mint new access
+// tokens.
+func setAccountState(ctx context.Context, q *database.Queries, userID uuid.UUID, state string, suspendedUntil time.Time) (database.User, error) {
+	params := database.SetAccountStateParams{AccountState: state, ID: userID}
+	if state == AccountSuspended {
+		params.SuspendedUntil = sql.NullTime{Time: suspendedUntil, Valid: true}
+	}
+	user, err := q.SetAccountState(ctx, params)
+	if err != nil {
+		return database.User{}, err
+	}
+	if state == AccountSuspended || state == AccountDeactivated {
+		if err := q.RevokeUserRefreshTokens(ctx, userID); err != nil {
+			return database.User{}, err
+		}
+	}
+	return user, nil
+}
+
+// parseSuspension reads a suspension length, falling back to the default
+// when none is given.
+func parseSuspension(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultSuspension, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 || d > maxSuspension {
+		return 0, fmt.Errorf("duration must be a positive duration up to %s", maxSuspension)
+	}
+	return d, nil
+}
+
+// canRestrict reports whether moderator may change target's account
+// state: only admins can act on staff.
+func canRestrict(moderator, target database.User) bool {
+	return !auth.Role(target.Role).Includes(auth.RoleModerator) || auth.Role(moderator.Role).Includes(auth.RoleAdmin)
+}
+
+func (cfg *apiConfig) handleSetAccountState(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		State    string `json:"state"`
+		Duration string `json:"duration"`
+		Reason   string `json:"reason"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !accountStates[params.State] {
+		respondWithError(w, http.StatusBadRequest, "state must be active, suspended, shadow_banned or deactivated", nil)
+		return
+	}
+	var suspendedUntil time.Time
+	if params.State == AccountSuspended {
+		d, err := parseSuspension(params.Duration)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		suspendedUntil = time.Now().UTC().Add(d)
+	}
+	if userID == moderator.ID {
+		respondWithError(w, http.StatusBadRequest, "moderators cannot change their own account state", fmt.Errorf("self state change by %s", moderator.ID))
+		return
+	}
+	target, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error looking up user", err)
+		return
+	}
+	if !canRestrict(moderator, target) {
+		respondWithError(w, http.StatusForbidden, "only admins can change the account state of staff", nil)
+		return
+	}
+	var updated database.User
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		updated, err = setAccountState(context.Background(), q, userID, params.State, suspendedUntil)
+		if err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, moderator, "user.state."+params.State, "user", updated.ID.String(), params.Reason)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error updating account state", err)
+		return
+	}
+	user := User{
+		ID:           updated.ID,
+		CreatedAt:    updated.CreatedAt,
+		UpdatedAt:    updated.UpdatedAt,
+		Email:        updated.Email,
+		IsChirpyRed:  cfg.isChirpyRed(updated.ID),
+		Role:         updated.Role,
+		Handle:       updated.Handle.String,
+		AccountState: accountState(updated),
+	}
+	if updated.SuspendedUntil.Valid {
+		user.SuspendedUntil = &updated.SuspendedUntil.Time
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
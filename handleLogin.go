@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/jcfullmer/chirpy/internal/audit"
 	"github.com/jcfullmer/chirpy/internal/auth"
 	"github.com/jcfullmer/chirpy/internal/database"
 )
@@ -30,12 +31,27 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, req *http.Request) {
 	}
 	u, err := cfg.db.LoginUser(context.Background(), params.Email)
 	if err != nil {
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			Action:   audit.ActionLoginFailed,
+			Metadata: map[string]any{"email": params.Email, "reason": "unknown_email"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password", err)
 		return
 	}
 	loginBool, err := auth.CheckPasswordHash(params.Password, u.HashedPassword)
 	switch loginBool {
 	case true:
+		if err := checkAccountActive(u); err != nil {
+			cfg.audit.Record(context.Background(), req, audit.Event{
+				ActorID:    u.ID,
+				Action:     audit.ActionLoginFailed,
+				TargetType: "user",
+				TargetID:   u.ID.String(),
+				Metadata:   map[string]any{"email": params.Email, "reason": accountState(u)},
+			})
+			respondWithAccountError(w, u, err)
+			return
+		}
 		token, err := auth.MakeJWT(u.ID, cfg.JWTSecret)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error creating token", err)
@@ -62,11 +78,26 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, req *http.Request) {
 			Email:        u.Email,
 			Token:        token,
 			RefreshToken: refreshTokenDB,
-			IsChirpyRed:  u.IsChirpyRed.Bool,
+			IsChirpyRed:  cfg.isChirpyRed(u.ID),
+			Role:         u.Role,
+			Handle:       u.Handle.String,
 		}
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			ActorID:    u.ID,
+			Action:     audit.ActionLogin,
+			TargetType: "user",
+			TargetID:   u.ID.String(),
+		})
 		respondWithJSON(w, http.StatusOK, user)
 		log.Printf("Logged in user %s", user.Email)
 	default:
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			ActorID:    u.ID,
+			Action:     audit.ActionLoginFailed,
+			TargetType: "user",
+			TargetID:   u.ID.String(),
+			Metadata:   map[string]any{"email": params.Email, "reason": "bad_password"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password", err)
 	}
 }
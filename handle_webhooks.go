@@ -2,53 +2,193 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
 	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
 )
 
-func (cfg *apiConfig) handleWebhooks(w http.ResponseWriter, r *http.Request) {
-	reqApiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "error getting api key", err)
+const (
+	maxWebhookBodyBytes  = 1 << 20
+	polkaEventIDHeader   = "X-Polka-Event-Id"
+	webhookSourcePolka   = "polka"
+	webhookStatusIgnored = "ignored"
+	webhookStatusDone    = "processed"
+)
+
+var errWebhookUserNotFound = errors.New("webhook user not found")
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           string     `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// authenticateWebhook accepts a signed delivery when signing secrets are
+// configured, falling back to the legacy ApiKey header unless signatures are
+// required.
+func (cfg *apiConfig) authenticateWebhook(headers http.Header, body []byte) error {
+	if cfg.polkaVerifier.Enabled() && headers.Get(auth.WebhookSignatureHeader) != "" {
+		return cfg.polkaVerifier.Verify(headers, body)
 	}
-	if reqApiKey != cfg.PolkaKey {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+	if cfg.polkaSignedOnly {
+		return auth.ErrMissingSignature
 	}
-	type reqParams struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+	reqApiKey, err := auth.GetAPIKey(headers)
+	if err != nil {
+		return err
 	}
-	req := reqParams{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&req)
+	if !auth.APIKeyMatches(reqApiKey, cfg.PolkaKeys) {
+		return fmt.Errorf("invalid api key")
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to decode json", err)
+		respondWithError(w, http.StatusBadRequest, "failed to read body", err)
 		return
 	}
-	if req.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	if err := cfg.authenticateWebhook(r.Header, body); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "webhook authentication failed", err)
 		return
 	}
-	userID, err := uuid.Parse(req.Data.UserID)
+	req := polkaEvent{}
+	err = json.Unmarshal(body, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusNoContent)
+		respondWithError(w, http.StatusBadRequest, "failed to decode json", err)
 		return
 	}
-	err = cfg.db.UpgradeUser(context.Background(), userID)
-	if err == sql.ErrNoRows {
-		w.WriteHeader(http.StatusNotFound)
+
+	// Polka retries until it sees a 2xx or 404, so every delivery is stored
+	// under its event ID and only reprocessed if an earlier attempt failed.
+	eventID := req.ID
+	if eventID == "" {
+		eventID = r.Header.Get(polkaEventIDHeader)
+	}
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	_, err = cfg.db.InsertWebhookEvent(context.Background(), database.InsertWebhookEventParams{
+		ID:      eventID,
+		Source:  webhookSourcePolka,
+		Event:   req.Event,
+		Payload: body,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "error storing webhook event", err)
 		return
+	}
+
+	w.WriteHeader(cfg.processWebhookEvent(r, eventID, false))
+}
+
+// processWebhookEvent claims a stored event, applies it and records the
+// outcome on it. Finished events are only applied again when replay is
+// set. The returned status code tells Polka whether to retry: 5xx for
+// transient failures, 409 while another delivery of the same event is
+// being processed, 404 for unknown users and 204 otherwise.
+func (cfg *apiConfig) processWebhookEvent(r *http.Request, eventID string, replay bool) int {
+	event, err := cfg.db.ClaimWebhookEvent(context.Background(), database.ClaimWebhookEventParams{
+		ID:     eventID,
+		Replay: replay,
+	})
+	if err == sql.ErrNoRows {
+		return cfg.unclaimedWebhookStatus(eventID)
 	} else if err != nil {
-		w.WriteHeader(http.StatusNoContent)
-		return
+		log.Printf("error claiming webhook event: %s", err)
+		return http.StatusInternalServerError
+	}
+	return cfg.applyWebhookEvent(r, event)
+}
+
+// applyWebhookEvent applies an event claimed by ClaimWebhookEvent and
+// records the outcome on it, returning the status code as
+// processWebhookEvent does.
+func (cfg *apiConfig) applyWebhookEvent(r *http.Request, event database.WebhookEvent) int {
+	status, err := cfg.applyPolkaEvent(r, event.Payload)
+	if err != nil {
+		markErr := cfg.db.MarkWebhookEventFailed(context.Background(), database.MarkWebhookEventFailedParams{
+			LastError: err.Error(),
+			ID:        event.ID,
+		})
+		if markErr != nil {
+			log.Printf("error marking webhook event failed: %s", markErr)
+		}
+		if errors.Is(err, errWebhookUserNotFound) {
+			return http.StatusNotFound
+		}
+		log.Printf("error processing webhook event %s: %s", event.ID, err)
+		return http.StatusInternalServerError
+	}
+	err = cfg.db.MarkWebhookEventProcessed(context.Background(), database.MarkWebhookEventProcessedParams{
+		Status: status,
+		ID:     event.ID,
+	})
+	if err != nil {
+		log.Printf("error marking webhook event processed: %s", err)
+		return http.StatusInternalServerError
+	}
+	return http.StatusNoContent
+}
+
+// unclaimedWebhookStatus answers a delivery whose event couldn't be
+// claimed: a duplicate of a finished event is acknowledged, one that is
+// still being processed elsewhere is asked to retry later.
+func (cfg *apiConfig) unclaimedWebhookStatus(eventID string) int {
+	event, err := cfg.db.GetWebhookEvent(context.Background(), eventID)
+	if err != nil {
+		log.Printf("error loading webhook event: %s", err)
+		return http.StatusInternalServerError
+	}
+	if event.Status == webhookStatusDone || event.Status == webhookStatusIgnored {
+		return http.StatusNoContent
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return http.StatusConflict
+}
 
+func (cfg *apiConfig) applyPolkaEvent(r *http.Request, payload []byte) (string, error) {
+	req := polkaEvent{}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return "", err
+	}
+	userID, err := uuid.Parse(req.Data.UserID)
+	if err != nil {
+		return webhookStatusIgnored, nil
+	}
+	handled, err := cfg.applySubscriptionEvent(req.Event, userID, req.Data.Plan, req.Data.CurrentPeriodEnd)
+	if err != nil {
+		return "", err
+	}
+	if !handled {
+		return webhookStatusIgnored, nil
+	}
+	action := audit.ActionWebhookSubscription
+	if req.Event == "user.upgraded" {
+		action = audit.ActionWebhookUpgrade
+	}
+	cfg.audit.Record(context.Background(), r, audit.Event{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"event": req.Event, "plan": req.Data.Plan},
+	})
+	return webhookStatusDone, nil
 }
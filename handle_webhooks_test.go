@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+var webhookEventColumns = []string{"id", "source", "event", "payload", "status", "attempts", "last_error", "received_at", "updated_at", "processed_at"}
+
+func newWebhookRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+	req.Header.Set("Authorization", "ApiKey polka-key")
+	return req
+}
+
+func newWebhookTestConfig(t *testing.T) (*apiConfig, sqlmock.Sqlmock) {
+	cfg, mock := newTestConfig(t)
+	cfg.PolkaKeys = []string{"polka-key"}
+	cfg.polkaVerifier = auth.NewWebhookVerifier(nil, time.Minute)
+	return cfg, mock
+}
+
+func webhookEventRow(id, status, payload string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(webhookEventColumns).
+		AddRow(id, webhookSourcePolka, "user.unknown", []byte(payload), status, 1, "", now, now, nil)
+}
+
+func TestWebhookDuplicateWhileProcessing(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	body := `{"id":"evt_1","event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("INSERT INTO webhook_events").WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WithArgs("evt_1", false).
+		WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("SELECT .* FROM webhook_events").
+		WithArgs("evt_1").
+		WillReturnRows(webhookEventRow("evt_1", "processing", body))
+
+	rec := httptest.NewRecorder()
+	cfg.handleWebhooks(rec, newWebhookRequest(body))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestWebhookDuplicateOfFinishedEvent(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	body := `{"id":"evt_1","event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("INSERT INTO webhook_events").WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("SELECT .* FROM webhook_events").
+		WillReturnRows(webhookEventRow("evt_1", webhookStatusDone, body))
+
+	rec := httptest.NewRecorder()
+	cfg.handleWebhooks(rec, newWebhookRequest(body))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestWebhookFirstDeliveryIsClaimedAndProcessed(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	body := `{"id":"evt_1","event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("INSERT INTO webhook_events").
+		WillReturnRows(webhookEventRow("evt_1", "received", body))
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WithArgs("evt_1", false).
+		WillReturnRows(webhookEventRow("evt_1", "processing", body))
+	mock.ExpectExec("UPDATE webhook_events\\s+SET status = \\$1").
+		WithArgs(webhookStatusIgnored, "evt_1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	cfg.handleWebhooks(rec, newWebhookRequest(body))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestWebhookDedupesOnBodyHashWithoutID(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	body := `{"event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("INSERT INTO webhook_events").
+		WithArgs(sqlmock.AnyArg(), webhookSourcePolka, "user.unknown", []byte(body)).
+		WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WithArgs(hashedEventID{}, false).
+		WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectQuery("SELECT .* FROM webhook_events").
+		WillReturnRows(webhookEventRow("sha256:x", webhookStatusIgnored, body))
+
+	rec := httptest.NewRecorder()
+	cfg.handleWebhooks(rec, newWebhookRequest(body))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+// hashedEventID matches the event ID derived from a body without one.
+type hashedEventID struct{}
+
+func (hashedEventID) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "sha256:")
+}
+
+func newReplayRequest(eventID string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks/events/"+eventID+"/replay", nil)
+	req.SetPathValue("eventID", eventID)
+	return req
+}
+
+func TestReplayWebhookEventIsRecordedWithTheClaim(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	admin := database.User{ID: uuid.New(), Role: "admin"}
+	body := `{"id":"evt_1","event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("SELECT .* FROM webhook_events").WithArgs("evt_1").
+		WillReturnRows(webhookEventRow("evt_1", webhookStatusDone, body))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WithArgs("evt_1", true).
+		WillReturnRows(webhookEventRow("evt_1", "processing", body))
+	mock.ExpectExec("INSERT INTO admin_actions").
+		WithArgs(admin.ID, "admin", "webhook.replay", "webhook_event", "evt_1", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE webhook_events\\s+SET status = \\$1").
+		WithArgs(webhookStatusIgnored, "evt_1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT .* FROM webhook_events").WithArgs("evt_1").
+		WillReturnRows(webhookEventRow("evt_1", webhookStatusIgnored, body))
+
+	rec := httptest.NewRecorder()
+	cfg.handleReplayWebhookEvent(rec, newReplayRequest("evt_1"), admin)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestReplayWebhookEventWhileProcessing(t *testing.T) {
+	cfg, mock := newWebhookTestConfig(t)
+	body := `{"id":"evt_1","event":"user.unknown","data":{"user_id":"not-a-uuid"}}`
+	mock.ExpectQuery("SELECT .* FROM webhook_events").WithArgs("evt_1").
+		WillReturnRows(webhookEventRow("evt_1", "processing", body))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE webhook_events\\s+SET status = 'processing'").
+		WithArgs("evt_1", true).
+		WillReturnRows(sqlmock.NewRows(webhookEventColumns))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	cfg.handleReplayWebhookEvent(rec, newReplayRequest("evt_1"), database.User{ID: uuid.New(), Role: "admin"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
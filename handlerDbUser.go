@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
 	"github.com/jcfullmer/chirpy/internal/auth"
 	"github.com/jcfullmer/chirpy/internal/database"
 )
@@ -21,12 +25,30 @@ type User struct {
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Role         string    `json:"role"`
+	Handle       string    `json:"handle,omitempty"`
+	// AccountState and SuspendedUntil are only filled in for staff.
+	AccountState   string     `json:"account_state,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,15}$`)
+
+// normalizeHandle lower-cases a handle, strips a leading @ and checks it
+// against the characters mentions can match.
+func normalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !handlePattern.MatchString(handle) {
+		return "", fmt.Errorf("handle must be 3-15 letters, digits or underscores")
+	}
+	return handle, nil
 }
 
 func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 	decoder := json.NewDecoder(req.Body)
 	params := parameters{}
@@ -44,8 +66,19 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, req *http.Request)
 		Email:          params.Email,
 		HashedPassword: hashedPW,
 	}
+	if params.Handle != "" {
+		handle, err := normalizeHandle(params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		CreateUserParams.Handle = sql.NullString{String: handle, Valid: true}
+	}
 	newUser, err := cfg.db.CreateUser(context.Background(), CreateUserParams)
-	if err != nil {
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle already taken", err)
+		return
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create a user", err)
 		return
 	}
@@ -55,29 +88,22 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, req *http.Request)
 		UpdatedAt:   newUser.UpdatedAt,
 		Email:       newUser.Email,
 		IsChirpyRed: false,
+		Role:        string(auth.RoleUser),
+		Handle:      newUser.Handle.String,
 	}
 	respondWithJSON(w, http.StatusCreated, u)
 	log.Printf("New User created with email: %s", u.Email)
 }
 
-func (cfg *apiConfig) handlerUpdateLogin(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "bad token", err)
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "not authorized", err)
-		return
-	}
+func (cfg *apiConfig) handlerUpdateLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID := user.ID
 	type reqParams struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 	params := reqParams{}
 	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "not authorized", err)
 		return
@@ -100,11 +126,56 @@ func (cfg *apiConfig) handlerUpdateLogin(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "error updating user", err)
 		return
 	}
+	cfg.audit.Record(context.Background(), r, audit.Event{
+		ActorID:    userID,
+		Action:     audit.ActionCredentialsUpdate,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"email": updatedUser.Email},
+	})
 	respondWithJSON(w, http.StatusOK, User{
 		ID:          updatedUser.ID,
 		CreatedAt:   updatedUser.CreatedAt,
 		UpdatedAt:   updatedUser.CreatedAt,
 		Email:       updatedUser.Email,
-		IsChirpyRed: updatedUser.IsChirpyRed.Bool,
+		IsChirpyRed: cfg.isChirpyRed(updatedUser.ID),
+		Role:        updatedUser.Role,
+		Handle:      updatedUser.Handle.String,
+	})
+}
+
+func (cfg *apiConfig) handleSetHandle(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Handle string `json:"handle"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	handle, err := normalizeHandle(params.Handle)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	updated, err := cfg.db.SetUserHandle(context.Background(), database.SetUserHandleParams{
+		Handle: sql.NullString{String: handle, Valid: true},
+		ID:     user.ID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Handle already taken", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error updating handle", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:          updated.ID,
+		CreatedAt:   updated.CreatedAt,
+		UpdatedAt:   updated.UpdatedAt,
+		Email:       updated.Email,
+		IsChirpyRed: cfg.isChirpyRed(updated.ID),
+		Role:        updated.Role,
+		Handle:      updated.Handle.String,
 	})
 }
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type AdminAction struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ActorID    uuid.UUID `json:"actor_id"`
+	ActorRole  string    `json:"actor_role"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id"`
+	Reason     string    `json:"reason"`
+}
+
+// recordAdminAction writes the admin action and its audit event through q.
+// Call it in the same transaction as the change so an action is never
+// applied without being recorded.
+func recordAdminAction(ctx context.Context, q *database.Queries, r *http.Request, actor database.User, action, targetType, targetID, reason string) error {
+	err := q.CreateAdminAction(ctx, database.CreateAdminActionParams{
+		ActorID:    actor.ID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+	})
+	if err != nil {
+		return err
+	}
+	return audit.Write(ctx, q, r, audit.Event{
+		ActorID:    actor.ID,
+		Action:     "admin." + action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   map[string]any{"role": actor.Role, "reason": reason},
+	})
+}
+
+func (cfg *apiConfig) handleHideChirp(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		c, err := q.HideChirp(context.Background(), chirpID)
+		if err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, moderator, "chirp.hide", "chirp", c.ID.String(), params.Reason)
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error hiding chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnhideChirp(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		c, err := q.UnhideChirp(context.Background(), chirpID)
+		if err != nil {
+			return err
+		}
+		if err := releaseHeldChirp(context.Background(), q, c.ID); err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, moderator, "chirp.unhide", "chirp", c.ID.String(), "")
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error unhiding chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUnshadowChirp makes a chirp spam scoring shadowed visible to
+// everyone again.
+func (cfg *apiConfig) handleUnshadowChirp(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		c, err := q.UnshadowChirp(context.Background(), chirpID)
+		if err != nil {
+			return err
+		}
+		if err := releaseHeldChirp(context.Background(), q, c.ID); err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, moderator, "chirp.unshadow", "chirp", c.ID.String(), "")
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error unshadowing chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleListUsers(w http.ResponseWriter, r *http.Request, admin database.User) {
+	limit, offset := parsePagination(r)
+	usersDB, err := cfg.db.ListUsers(context.Background(), database.ListUsersParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing users", err)
+		return
+	}
+	result := []User{}
+	for _, u := range usersDB {
+		user := User{
+			ID:          u.ID,
+			CreatedAt:   u.CreatedAt,
+			UpdatedAt:   u.UpdatedAt,
+			Email:       u.Email,
+			IsChirpyRed: u.IsChirpyRed,
+			Role:        u.Role,
+			Handle:      u.Handle.String,
+			AccountState: accountState(database.User{
+				AccountState:   u.AccountState,
+				SuspendedUntil: u.SuspendedUntil,
+			}),
+		}
+		if u.SuspendedUntil.Valid {
+			user.SuspendedUntil = &u.SuspendedUntil.Time
+		}
+		result = append(result, user)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request, admin database.User) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		Role   string `json:"role"`
+		Reason string `json:"reason"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unknown role", err)
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, http.StatusBadRequest, "admins cannot change their own role", fmt.Errorf("self role change by %s", admin.ID))
+		return
+	}
+	var updated database.User
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		updated, err = q.SetUserRole(context.Background(), database.SetUserRoleParams{
+			Role: string(role),
+			ID:   userID,
+		})
+		if err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, admin, "user.role."+string(role), "user", updated.ID.String(), params.Reason)
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error updating role", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:          updated.ID,
+		CreatedAt:   updated.CreatedAt,
+		UpdatedAt:   updated.UpdatedAt,
+		Email:       updated.Email,
+		IsChirpyRed: cfg.isChirpyRed(updated.ID),
+		Role:        updated.Role,
+		Handle:      updated.Handle.String,
+	})
+}
+
+func (cfg *apiConfig) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request, admin database.User) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, http.StatusBadRequest, "admins cannot delete themselves", fmt.Errorf("self delete by %s", admin.ID))
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if err := q.QueueOwnerBlobDeletions(context.Background(), userID); err != nil {
+			return err
+		}
+		n, err := q.DeleteUser(context.Background(), userID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		return recordAdminAction(context.Background(), q, r, admin, "user.delete", "user", userID.String(), "")
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleListAdminActions(w http.ResponseWriter, r *http.Request, admin database.User) {
+	limit, offset := parsePagination(r)
+	actions, err := cfg.db.ListAdminActions(context.Background(), database.ListAdminActionsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing admin actions", err)
+		return
+	}
+	result := []AdminAction{}
+	for _, a := range actions {
+		result = append(result, AdminAction{
+			ID:         a.ID,
+			CreatedAt:  a.CreatedAt,
+			ActorID:    a.ActorID,
+			ActorRole:  a.ActorRole,
+			Action:     a.Action,
+			TargetType: a.TargetType,
+			TargetID:   a.TargetID,
+			Reason:     a.Reason,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+func (cfg *apiConfig) handleListAuditEvents(w http.ResponseWriter, r *http.Request, admin database.User) {
+	query := r.URL.Query()
+	limit, offset := parsePagination(r)
+	params := database.ListAuditEventsParams{
+		Limit:  limit,
+		Offset: offset,
+	}
+	if s := query.Get("user_id"); s != "" {
+		userID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid user_id", err)
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	if s := query.Get("action"); s != "" {
+		params.Action = sql.NullString{String: s, Valid: true}
+	}
+	for _, bound := range []struct {
+		name string
+		dest *sql.NullTime
+	}{{"since", &params.Since}, {"until", &params.Until}} {
+		s := query.Get(bound.name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid "+bound.name+", expected RFC 3339", err)
+			return
+		}
+		*bound.dest = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+	events, err := cfg.db.ListAuditEvents(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing audit events", err)
+		return
+	}
+	result := []AuditEvent{}
+	for _, e := range events {
+		event := AuditEvent{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			IPAddress:  e.IpAddress,
+			UserAgent:  e.UserAgent,
+			Metadata:   e.Metadata,
+		}
+		if e.ActorID.Valid {
+			event.ActorID = &e.ActorID.UUID
+		}
+		result = append(result, event)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+var auditEventColumns = []string{"id", "created_at", "actor_id", "action", "target_type", "target_id", "ip_address", "user_agent", "metadata"}
+
+func TestListAuditEventsFilters(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	actor := uuid.New()
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT .* FROM audit_events").
+		WithArgs(actor, audit.ActionLogin, since, nil, int32(defaultPageLimit), int32(0)).
+		WillReturnRows(sqlmock.NewRows(auditEventColumns).
+			AddRow(1, since, actor, audit.ActionLogin, "user", actor.String(), "192.0.2.1", "", []byte(`{}`)))
+
+	url := "/admin/audit?user_id=" + actor.String() + "&action=" + audit.ActionLogin + "&since=2026-01-02T04:04:05%2B01:00"
+	rec := httptest.NewRecorder()
+	cfg.handleListAuditEvents(rec, httptest.NewRequest(http.MethodGet, url, nil), database.User{})
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestListAuditEventsRejectsBadFilters(t *testing.T) {
+	for _, query := range []string{"user_id=nope", "since=yesterday", "until=2026-01-02"} {
+		cfg, _ := newTestConfig(t)
+		rec := httptest.NewRecorder()
+		cfg.handleListAuditEvents(rec, httptest.NewRequest(http.MethodGet, "/admin/audit?"+query, nil), database.User{})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type WebhookEvent struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(e database.WebhookEvent) WebhookEvent {
+	event := WebhookEvent{
+		ID:         e.ID,
+		Source:     e.Source,
+		Event:      e.Event,
+		Payload:    e.Payload,
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		LastError:  e.LastError,
+		ReceivedAt: e.ReceivedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}
+	if e.ProcessedAt.Valid {
+		event.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return event
+}
+
+func (cfg *apiConfig) handleListWebhookEvents(w http.ResponseWriter, r *http.Request, admin database.User) {
+	limit, offset := parsePagination(r)
+	params := database.ListWebhookEventsParams{
+		Limit:  limit,
+		Offset: offset,
+	}
+	if s := r.URL.Query().Get("status"); s != "" {
+		params.Status = sql.NullString{String: s, Valid: true}
+	}
+	events, err := cfg.db.ListWebhookEvents(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing webhook events", err)
+		return
+	}
+	result := []WebhookEvent{}
+	for _, e := range events {
+		result = append(result, webhookEventFromDB(e))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleGetWebhookEvent(w http.ResponseWriter, r *http.Request, admin database.User) {
+	event, err := cfg.db.GetWebhookEvent(context.Background(), r.PathValue("eventID"))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading webhook event", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
+
+func (cfg *apiConfig) handleReplayWebhookEvent(w http.ResponseWriter, r *http.Request, admin database.User) {
+	event, err := cfg.db.GetWebhookEvent(context.Background(), r.PathValue("eventID"))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading webhook event", err)
+		return
+	}
+	// The replay is recorded together with the claim, so an event is never
+	// reapplied without a record of who asked for it.
+	var claimed database.WebhookEvent
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		var err error
+		claimed, err = q.ClaimWebhookEvent(context.Background(), database.ClaimWebhookEventParams{
+			ID:     event.ID,
+			Replay: true,
+		})
+		if err != nil {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, admin, "webhook.replay", "webhook_event", claimed.ID, "")
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Webhook event is being processed", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error replaying webhook event", err)
+		return
+	}
+	cfg.applyWebhookEvent(r, claimed)
+	event, err = cfg.db.GetWebhookEvent(context.Background(), event.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading webhook event", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
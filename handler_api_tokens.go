@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/jcfullmer/chirpy/internal/audit"
 	"github.com/jcfullmer/chirpy/internal/auth"
 )
 
@@ -17,6 +18,10 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request) {
 	}
 	tokenDB, err := cfg.db.RefreshTokenLookup(context.Background(), token)
 	if err == sql.ErrNoRows {
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			Action:   audit.ActionRefreshFailed,
+			Metadata: map[string]any{"reason": "unknown_token"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "Token not found", err)
 		return
 	} else if err != nil {
@@ -24,18 +29,48 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	if tokenDB.ExpiresAt.Before(time.Now().UTC()) {
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			ActorID:  tokenDB.UserID,
+			Action:   audit.ActionRefreshFailed,
+			Metadata: map[string]any{"reason": "expired"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "token expired", err)
 		return
 	}
 	if tokenDB.RevokedAt.Valid {
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			ActorID:  tokenDB.UserID,
+			Action:   audit.ActionRefreshFailed,
+			Metadata: map[string]any{"reason": "revoked"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
 		return
 	}
+	user, err := cfg.db.GetUserByID(context.Background(), tokenDB.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+	if err := checkAccountActive(user); err != nil {
+		cfg.audit.Record(context.Background(), req, audit.Event{
+			ActorID:  tokenDB.UserID,
+			Action:   audit.ActionRefreshFailed,
+			Metadata: map[string]any{"reason": accountState(user)},
+		})
+		respondWithAccountError(w, user, err)
+		return
+	}
 	newToken, err := auth.MakeJWT(tokenDB.UserID, cfg.JWTSecret)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error creating new token", err)
 		return
 	}
+	cfg.audit.Record(context.Background(), req, audit.Event{
+		ActorID:    tokenDB.UserID,
+		Action:     audit.ActionRefresh,
+		TargetType: "user",
+		TargetID:   tokenDB.UserID.String(),
+	})
 	type tokenStruct struct {
 		Token string `json:"token"`
 	}
@@ -49,11 +84,22 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, req *http.Request) {
 	token, err := auth.GetBearerToken(req.Header)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't get token from auth header", err)
+		return
 	}
-	err = cfg.db.RevokeToken(context.Background(), token)
-	if err != nil {
+	userID, err := cfg.db.RevokeToken(context.Background(), token)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "Token not found", err)
+		return
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error revoking token", err)
+		return
 	}
+	cfg.audit.Record(context.Background(), req, audit.Event{
+		ActorID:    userID,
+		Action:     audit.ActionRevoke,
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
 	w.WriteHeader(http.StatusNoContent)
 
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+var (
+	errReplyBlocked  = errors.New("reply blocked")
+	errFollowBlocked = errors.New("follow blocked")
+)
+
+// viewerID identifies the caller of a public endpoint so block and mute
+// filters can apply. Anonymous callers get a NULL viewer; a token that
+// doesn't check out is an error rather than silently anonymous.
+func (cfg *apiConfig) viewerID(r *http.Request) (uuid.NullUUID, error) {
+	user, ok, err := cfg.optionalUser(r)
+	if !ok {
+		return uuid.NullUUID{}, nil
+	} else if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: user.ID, Valid: true}, nil
+}
+
+// hiddenAuthors returns the authors whose chirps viewer's live streams
+// should skip.
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids, err := cfg.db.ListHiddenAuthorIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+// hiddenAuthorSet is the live copy of hiddenAuthors kept by an open
+// stream. It is reloaded whenever the viewer's blocks or mutes change and
+// checked again as each event is delivered, so a block takes effect on
+// connections that were already open.
+type hiddenAuthorSet struct {
+	viewerID uuid.UUID
+	mu       sync.RWMutex
+	ids      map[uuid.UUID]bool
+}
+
+// loadHiddenAuthorSet loads the set for viewerID. uuid.Nil, an anonymous
+// viewer, hides nobody.
+func (cfg *apiConfig) loadHiddenAuthorSet(ctx context.Context, viewerID uuid.UUID) (*hiddenAuthorSet, error) {
+	s := &hiddenAuthorSet{viewerID: viewerID, ids: map[uuid.UUID]bool{}}
+	if viewerID == uuid.Nil {
+		return s, nil
+	}
+	return s, cfg.reloadHiddenAuthorSet(ctx, s)
+}
+
+func (cfg *apiConfig) reloadHiddenAuthorSet(ctx context.Context, s *hiddenAuthorSet) error {
+	ids, err := cfg.hiddenAuthors(ctx, s.viewerID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = ids
+	return nil
+}
+
+func (s *hiddenAuthorSet) has(authorID uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ids[authorID]
+}
+
+// changedBy reports whether e says the set is out of date.
+func (s *hiddenAuthorSet) changedBy(e stream.Event) bool {
+	return s.viewerID != uuid.Nil && e.Type == stream.EventHiddenAuthorsChanged && e.RecipientID == s.viewerID
+}
+
+// hiddenAuthorsChanged tells the open streams of each user that who they
+// hide has changed.
+func hiddenAuthorsChanged(ctx context.Context, q *database.Queries, userIDs ...uuid.UUID) error {
+	for _, id := range userIDs {
+		payload := map[string]uuid.UUID{"recipient_id": id}
+		if _, err := outbox.Write(ctx, q, "user", id, stream.EventHiddenAuthorsChanged, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relationshipTarget parses and checks the {userID} of a block or mute
+// request.
+func (cfg *apiConfig) relationshipTarget(w http.ResponseWriter, r *http.Request, user database.User) (uuid.UUID, bool) {
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return uuid.Nil, false
+	}
+	if targetID == user.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't do that to yourself", nil)
+		return uuid.Nil, false
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), targetID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return uuid.Nil, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error looking up user", err)
+		return uuid.Nil, false
+	}
+	return targetID, true
+}
+
+// handleBlockUser blocks a user and drops any follows between the two, so
+// neither keeps seeing the other through a timeline.
+func (cfg *apiConfig) handleBlockUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	targetID, ok := cfg.relationshipTarget(w, r, user)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if _, err := q.BlockUser(context.Background(), database.BlockUserParams{
+			BlockerID: user.ID,
+			BlockedID: targetID,
+		}); err != nil {
+			return err
+		}
+		err := q.RemoveFollowsBetween(context.Background(), database.RemoveFollowsBetweenParams{
+			UserA: user.ID,
+			UserB: targetID,
+		})
+		if err != nil {
+			return err
+		}
+		return hiddenAuthorsChanged(context.Background(), q, user.ID, targetID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error blocking user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnblockUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		n, err := q.UnblockUser(context.Background(), database.UnblockUserParams{
+			BlockerID: user.ID,
+			BlockedID: targetID,
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		return hiddenAuthorsChanged(context.Background(), q, user.ID, targetID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unblocking user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleMuteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	targetID, ok := cfg.relationshipTarget(w, r, user)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if _, err := q.MuteUser(context.Background(), database.MuteUserParams{
+			MuterID: user.ID,
+			MutedID: targetID,
+		}); err != nil {
+			return err
+		}
+		return hiddenAuthorsChanged(context.Background(), q, user.ID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error muting user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnmuteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		n, err := q.UnmuteUser(context.Background(), database.UnmuteUserParams{
+			MuterID: user.ID,
+			MutedID: targetID,
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		return hiddenAuthorsChanged(context.Background(), q, user.ID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unmuting user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type Relationship struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleListBlocks(w http.ResponseWriter, r *http.Request, user database.User) {
+	blocks, err := cfg.db.ListBlocks(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error listing blocks", err)
+		return
+	}
+	result := []Relationship{}
+	for _, b := range blocks {
+		result = append(result, Relationship{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleListMutes(w http.ResponseWriter, r *http.Request, user database.User) {
+	mutes, err := cfg.db.ListMutes(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error listing mutes", err)
+		return
+	}
+	result := []Relationship{}
+	for _, m := range mutes {
+		result = append(result, Relationship{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+var outboxColumns = []string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "created_at", "published_at", "attempts", "next_attempt_at", "last_error", "txid"}
+
+// outboxRow returns the row InsertOutboxEvent hands back.
+func outboxRow(id int64, eventType string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(outboxColumns).
+		AddRow(id, "user", uuid.New(), eventType, []byte(`{}`), now, nil, 0, now, "", 1)
+}
+
+func TestBlockUserTellsBothUsersStreams(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := database.User{ID: uuid.New()}
+	target := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(target).WillReturnRows(userRow(target))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blocks").WithArgs(user.ID, target).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM follows").WillReturnResult(sqlmock.NewResult(0, 0))
+	for i, id := range []uuid.UUID{user.ID, target} {
+		mock.ExpectQuery("INSERT INTO outbox_events").
+			WithArgs("user", id, stream.EventHiddenAuthorsChanged, sqlmock.AnyArg()).
+			WillReturnRows(outboxRow(int64(i+1), stream.EventHiddenAuthorsChanged))
+	}
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users/"+target.String()+"/block", nil)
+	req.SetPathValue("userID", target.String())
+	rec := httptest.NewRecorder()
+	cfg.handleBlockUser(rec, req, user)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestUnmuteUserWithoutMuteChangesNothing(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := database.User{ID: uuid.New()}
+	target := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM mutes").WithArgs(user.ID, target).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/users/"+target.String()+"/mute", nil)
+	req.SetPathValue("userID", target.String())
+	rec := httptest.NewRecorder()
+	cfg.handleUnmuteUser(rec, req, user)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestStreamFilterReloadsHiddenAuthors(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.JWTSecret = "secret"
+	user, muted := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).WillReturnRows(userRow(user))
+	mock.ExpectQuery("SELECT blocked_id AS user_id").WithArgs(user).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery("SELECT blocked_id AS user_id").WithArgs(user).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(muted))
+
+	token, err := auth.MakeJWT(user, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/chirps?access_token="+token, nil)
+	filter, hidden, _, err := cfg.streamFilter(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp := stream.Event{Type: stream.EventChirpCreated, AuthorID: muted}
+	if !filter(chirp) {
+		t.Fatal("chirp filtered out before the mute")
+	}
+
+	changed := stream.Event{Type: stream.EventHiddenAuthorsChanged, RecipientID: user}
+	if !filter(changed) || !hidden.changedBy(changed) {
+		t.Fatal("change for the viewer not passed through")
+	}
+	if other := (stream.Event{Type: stream.EventHiddenAuthorsChanged, RecipientID: uuid.New()}); filter(other) {
+		t.Error("another user's change passed through")
+	}
+	if err := cfg.reloadHiddenAuthorSet(context.Background(), hidden); err != nil {
+		t.Fatal(err)
+	}
+	if filter(chirp) {
+		t.Error("muted author's chirp passed after reloading")
+	}
+}
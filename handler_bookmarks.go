@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/chirptext"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+const maxCollectionNameLength = 50
+
+var errInvalidCollection = errors.New("invalid bookmark collection")
+
+type BookmarkCollection struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+}
+
+func bookmarkCollectionFromDB(c database.BookmarkCollection) BookmarkCollection {
+	return BookmarkCollection{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Name:      c.Name,
+	}
+}
+
+// getOwnCollection loads a collection of user, treating other users'
+// collections as missing. A nil id means unfiled bookmarks and is always
+// fine.
+func (cfg *apiConfig) getOwnCollection(w http.ResponseWriter, user database.User, id *uuid.UUID) bool {
+	if id == nil {
+		return true
+	}
+	collection, err := cfg.db.GetBookmarkCollection(context.Background(), *id)
+	if err == sql.ErrNoRows || (err == nil && collection.UserID != user.ID) {
+		respondWithError(w, http.StatusNotFound, "Collection not found", err)
+		return false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error looking up collection", err)
+		return false
+	}
+	return true
+}
+
+// handleBookmarkChirp saves a chirp, optionally straight into a collection.
+// Bookmarking a chirp that is already bookmarked leaves it where it is;
+// PUT /api/bookmarks/{chirpID} moves it.
+func (cfg *apiConfig) handleBookmarkChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	c, err := cfg.db.GetVisibleChirp(context.Background(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if !cfg.getOwnCollection(w, user, params.CollectionID) {
+		return
+	}
+	_, err = cfg.db.AddBookmark(context.Background(), database.AddBookmarkParams{
+		UserID:       user.ID,
+		ChirpID:      c.ID,
+		CollectionID: nullUUID(params.CollectionID),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error bookmarking chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnbookmarkChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	_, err = cfg.db.RemoveBookmark(context.Background(), database.RemoveBookmarkParams{
+		UserID:  user.ID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing bookmark", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListBookmarks lists every bookmark newest first or, with
+// ?collection_id=, one collection in its saved order. collection_id=unfiled
+// lists the bookmarks that aren't in any collection.
+func (cfg *apiConfig) handleListBookmarks(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset := parsePagination(r)
+	viewerID := uuid.NullUUID{UUID: user.ID, Valid: true}
+	var chirpsDB []database.Chirp
+	var err error
+	switch collection := r.URL.Query().Get("collection_id"); collection {
+	case "":
+		chirpsDB, err = cfg.db.ListBookmarkedChirps(context.Background(), database.ListBookmarkedChirpsParams{
+			UserID: user.ID,
+			Limit:  limit,
+			Offset: offset,
+		})
+	default:
+		var collectionID *uuid.UUID
+		if collection != "unfiled" {
+			id, parseErr := uuid.Parse(collection)
+			if parseErr != nil {
+				respondWithError(w, http.StatusNotFound, "Not a Valid ID", parseErr)
+				return
+			}
+			collectionID = &id
+		}
+		if !cfg.getOwnCollection(w, user, collectionID) {
+			return
+		}
+		chirpsDB, err = cfg.db.ListCollectionChirps(context.Background(), database.ListCollectionChirpsParams{
+			UserID:       user.ID,
+			CollectionID: nullUUID(collectionID),
+			Limit:        limit,
+			Offset:       offset,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error listing bookmarks", err)
+		return
+	}
+	result, err := hydrateChirps(context.Background(), cfg.db, chirpsDB, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error listing bookmarks", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handleMoveBookmark files a bookmark into a collection, or takes it out
+// with a null collection_id, at position within that collection. Position
+// 0 is the top; positions past the end put it last.
+func (cfg *apiConfig) handleMoveBookmark(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+		Position     int32      `json:"position"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Position < 0 {
+		respondWithValidationError(w, newFieldError("position", "negative", errInvalidCollection, "position can't be negative"))
+		return
+	}
+	if !cfg.getOwnCollection(w, user, params.CollectionID) {
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		err := q.MakeRoomForBookmark(context.Background(), database.MakeRoomForBookmarkParams{
+			Index:        params.Position,
+			UserID:       user.ID,
+			CollectionID: nullUUID(params.CollectionID),
+			ChirpID:      chirpID,
+		})
+		if err != nil {
+			return err
+		}
+		moved, err := q.MoveBookmark(context.Background(), database.MoveBookmarkParams{
+			CollectionID: nullUUID(params.CollectionID),
+			Index:        params.Position,
+			UserID:       user.ID,
+			ChirpID:      chirpID,
+		})
+		if err != nil {
+			return err
+		}
+		if moved == 0 {
+			// Nothing to move, so don't keep the renumbering either.
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Bookmark not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moving bookmark", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeCollectionName reads {"name": ...} and checks it.
+func decodeCollectionName(r *http.Request) (string, error) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return "", err
+	}
+	if chirptext.IsBlank(params.Name) {
+		return "", newFieldError("name", "empty", errInvalidCollection, "collection name can't be empty")
+	}
+	if utf8.RuneCountInString(params.Name) > maxCollectionNameLength {
+		return "", newFieldError("name", "too_long", errInvalidCollection,
+			fmt.Sprintf("collection names are limited to %d characters", maxCollectionNameLength))
+	}
+	return params.Name, nil
+}
+
+func (cfg *apiConfig) handleCreateBookmarkCollection(w http.ResponseWriter, r *http.Request, user database.User) {
+	name, err := decodeCollectionName(r)
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	collection, err := cfg.db.CreateBookmarkCollection(context.Background(), database.CreateBookmarkCollectionParams{
+		UserID: user.ID,
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You already have a collection with that name", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, bookmarkCollectionFromDB(collection))
+}
+
+func (cfg *apiConfig) handleListBookmarkCollections(w http.ResponseWriter, r *http.Request, user database.User) {
+	collections, err := cfg.db.ListBookmarkCollections(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing collections", err)
+		return
+	}
+	result := []BookmarkCollection{}
+	for _, c := range collections {
+		result = append(result, BookmarkCollection{
+			ID:            c.ID,
+			CreatedAt:     c.CreatedAt,
+			UpdatedAt:     c.UpdatedAt,
+			Name:          c.Name,
+			BookmarkCount: c.BookmarkCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleRenameBookmarkCollection(w http.ResponseWriter, r *http.Request, user database.User) {
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	name, err := decodeCollectionName(r)
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	collection, err := cfg.db.RenameBookmarkCollection(context.Background(), database.RenameBookmarkCollectionParams{
+		Name:   name,
+		ID:     collectionID,
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Collection not found", err)
+		return
+	} else if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You already have a collection with that name", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error renaming collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, bookmarkCollectionFromDB(collection))
+}
+
+// handleDeleteBookmarkCollection deletes a collection. Its bookmarks are
+// kept and become unfiled.
+func (cfg *apiConfig) handleDeleteBookmarkCollection(w http.ResponseWriter, r *http.Request, user database.User) {
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	n, err := cfg.db.DeleteBookmarkCollection(context.Background(), database.DeleteBookmarkCollectionParams{
+		ID:     collectionID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting collection", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Collection not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func newMoveBookmarkRequest(chirpID uuid.UUID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/api/bookmarks/"+chirpID.String(), strings.NewReader(body))
+	req.SetPathValue("chirpID", chirpID.String())
+	return req
+}
+
+func TestMoveBookmark(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		moved int64
+		want  int
+	}{
+		{"Bookmarked", 1, http.StatusNoContent},
+		// The renumbering of the other bookmarks is rolled back.
+		{"Not Bookmarked", 0, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user, chirpID := uuid.New(), uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE bookmarks\\s+SET position = ordered.idx").
+				WithArgs(int32(2), user, nil, chirpID).
+				WillReturnResult(sqlmock.NewResult(0, 3))
+			mock.ExpectExec("UPDATE bookmarks\\s+SET collection_id").
+				WithArgs(nil, int32(2), user, chirpID).
+				WillReturnResult(sqlmock.NewResult(0, tc.moved))
+			if tc.moved == 0 {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handleMoveBookmark(rec, newMoveBookmarkRequest(chirpID, `{"position":2}`), database.User{ID: user})
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMoveBookmarkIntoSomeoneElsesCollection(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user, collectionID, chirpID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	mock.ExpectQuery("SELECT .* FROM bookmark_collections").WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "user_id", "name"}).
+			AddRow(collectionID, now, now, uuid.New(), "theirs"))
+
+	body := `{"collection_id":"` + collectionID.String() + `","position":0}`
+	rec := httptest.NewRecorder()
+	cfg.handleMoveBookmark(rec, newMoveBookmarkRequest(chirpID, body), database.User{ID: user})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,23 +12,161 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
 	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/chirptext"
 	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/entities"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+	"github.com/jcfullmer/chirpy/internal/spam"
+	"github.com/jcfullmer/chirpy/internal/unfurl"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	User_id   uuid.UUID `json:"user_id"`
+	ID        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Body      string            `json:"body"`
+	User_id   uuid.UUID         `json:"user_id"`
+	ReplyToID *uuid.UUID        `json:"reply_to_id,omitempty"`
+	Entities  entities.Entities `json:"entities"`
+	Media     []Attachment      `json:"media"`
+	Previews  []unfurl.Preview  `json:"link_previews"`
+	// Bookmarked is whether the viewer has bookmarked the chirp; always
+	// false for anonymous requests.
+	Bookmarked bool `json:"bookmarked"`
+	// Pinned marks the pinned chirps at the top of a profile listing.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+func chirpFromDB(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		User_id:   c.UserID,
+		// Hashtags and mentions are loaded from the stored entities by
+		// hydrateChirps. Links aren't about anyone, so they come straight
+		// from the body.
+		Entities: entities.Entities{
+			Hashtags: []entities.Hashtag{},
+			Mentions: []entities.Mention{},
+			URLs:     entities.Parse(c.Body).URLs,
+		},
+		Media:    []Attachment{},
+		Previews: []unfurl.Preview{},
+	}
+	if c.ReplyToID.Valid {
+		chirp.ReplyToID = &c.ReplyToID.UUID
+	}
+	return chirp
+}
+
+// hydrateChirps converts chirps to their API form, loading the related
+// rows for all of them with one query per relation. viewerID, when set,
+// fills in the viewer's bookmarked flags and drops mentions of users on
+// either side of a block with the viewer.
+func hydrateChirps(ctx context.Context, q *database.Queries, chirpsDB []database.Chirp, viewerID uuid.NullUUID) ([]Chirp, error) {
+	result := make([]Chirp, 0, len(chirpsDB))
+	ids := make([]uuid.UUID, 0, len(chirpsDB))
+	index := map[uuid.UUID]int{}
+	for _, c := range chirpsDB {
+		index[c.ID] = len(result)
+		ids = append(ids, c.ID)
+		result = append(result, chirpFromDB(c))
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	hashtags, err := q.ListHashtagsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hashtags {
+		i := index[h.ChirpID]
+		result[i].Entities.Hashtags = append(result[i].Entities.Hashtags, entities.Hashtag{
+			Tag:   h.Tag,
+			Start: int(h.StartOffset),
+			End:   int(h.EndOffset),
+		})
+	}
+	mentions, err := q.ListVisibleMentionsForChirps(ctx, database.ListVisibleMentionsForChirpsParams{
+		ChirpIds: ids,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mentions {
+		i := index[m.ChirpID]
+		body := []rune(result[i].Body)
+		start, end := int(m.StartOffset), int(m.EndOffset)
+		if start < 0 || end > len(body) || start+1 >= end {
+			continue
+		}
+		result[i].Entities.Mentions = append(result[i].Entities.Mentions, entities.Mention{
+			Handle: strings.ToLower(string(body[start+1 : end])),
+			Start:  start,
+			End:    end,
+		})
+	}
+	attachments, err := q.ListAttachmentsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		i := index[a.ChirpID.UUID]
+		result[i].Media = append(result[i].Media, attachmentFromDB(a))
+	}
+	previews, err := q.ListLinkPreviewsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range previews {
+		i := index[p.ChirpID]
+		result[i].Previews = append(result[i].Previews, unfurl.Preview{
+			URL:         p.Url,
+			Title:       p.Title,
+			Description: p.Description,
+			ImageURL:    p.ImageUrl,
+			SiteName:    p.SiteName,
+		})
+	}
+	if !viewerID.Valid {
+		return result, nil
+	}
+	bookmarked, err := q.ListBookmarkedChirpIDs(ctx, database.ListBookmarkedChirpIDsParams{
+		UserID:   viewerID.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range bookmarked {
+		result[index[id]].Bookmarked = true
+	}
+	return result, nil
+}
+
+func hydrateChirp(ctx context.Context, q *database.Queries, c database.Chirp, viewerID uuid.NullUUID) (Chirp, error) {
+	chirps, err := hydrateChirps(ctx, q, []database.Chirp{c}, viewerID)
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirps[0], nil
 }
 
 func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body    string    `json:"body"`
-		User_id uuid.UUID `json:"user_id"`
-		Token   string    `json:"token"`
+		Body      string       `json:"body"`
+		User_id   uuid.UUID    `json:"user_id"`
+		Token     string       `json:"token"`
+		ReplyToID *uuid.UUID   `json:"reply_to_id"`
+		Media     []chirpMedia `json:"media"`
+		PublishAt *time.Time   `json:"publish_at"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -46,89 +186,191 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	params.User_id = validUUID
-	validatedBody, err := validate_chirp(params.Body)
-	if err == fmt.Errorf("Chirp is too long") {
-		respondWithError(w, http.StatusBadRequest, "chirp is too long", err)
-		return
-	} else if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldnt process chirp", err)
+	author, err := cfg.db.GetUserByID(context.Background(), params.User_id)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
 		return
 	}
-	dbEntry := database.CreateChirpParams{
-		Body:   validatedBody,
-		UserID: params.User_id,
+	in := newChirp{Body: params.Body, ReplyToID: params.ReplyToID, Media: params.Media}
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, author, in, *params.PublishAt)
+		return
 	}
-	chirpDB, err := cfg.db.CreateChirp(context.Background(), dbEntry)
+	c, verdict, err := cfg.postChirp(context.Background(), author, in, nil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "error creating chirp in database", err)
+		respondWithChirpError(w, author, err)
 		return
 	}
-	c := Chirp{
-		ID:        chirpDB.ID,
-		CreatedAt: chirpDB.CreatedAt,
-		UpdatedAt: chirpDB.UpdatedAt,
-		Body:      chirpDB.Body,
-		User_id:   chirpDB.UserID,
+	// Chirps held for review say so; shadowed ones look like any other.
+	code := http.StatusCreated
+	if verdict == spam.Review {
+		code = http.StatusAccepted
 	}
-	respondWithJSON(w, http.StatusCreated, c)
+	respondWithJSON(w, code, c)
 	log.Printf("New chirp created: %v", c.ID)
 
 }
 
-func validate_chirp(body string) (string, error) {
-	const maxChirpLength = 140
-	if len(body) > maxChirpLength {
-		return "", fmt.Errorf("Chirp is too long")
+// newChirp is a chirp about to be posted, straight from the API or by the
+// scheduled chirp publisher.
+type newChirp struct {
+	Body      string
+	ReplyToID *uuid.UUID
+	Media     []chirpMedia
+}
+
+// postChirp checks a chirp against its author's account state, the length
+// limit, the word filter and the spam heuristics, then stores it along
+// with its media, entities and notifications. within, when not nil, runs
+// in the same transaction once the chirp exists so callers can make their
+// own writes all-or-nothing with it. The verdict says whether the chirp
+// was held for review.
+func (cfg *apiConfig) postChirp(ctx context.Context, author database.User, in newChirp, within func(q *database.Queries, c database.Chirp) error) (Chirp, spam.Verdict, error) {
+	if err := checkAccountActive(author); err != nil {
+		return Chirp{}, "", err
+	}
+	ent := cfg.entitlementsFor(author.ID)
+	checked, bodyErr := validate_chirp(in.Body, ent.MaxChirpLength, cfg.moderationFilter())
+	if err := validationErrors(bodyErr, cfg.validateChirpMedia(in.Media)); err != nil {
+		return Chirp{}, "", err
+	}
+	spamResult, err := cfg.scoreSpam(ctx, author, checked.Text, uuid.Nil)
+	if err != nil {
+		return Chirp{}, "", err
+	}
+	if spamResult.Verdict == spam.Reject {
+		return Chirp{}, "", newFieldError("body", "spam", errChirpSpam, errChirpSpam.Error())
+	}
+	// Shadow-banned authors and chirps held for spam reach nobody else, so
+	// they aren't announced to live subscribers, webhooks or notifications.
+	silent := accountState(author) == AccountShadowBanned || spamResult.Verdict != spam.Allow
+	dbEntry := database.CreateChirpParams{
+		Body:   checked.Text,
+		UserID: author.ID,
 	}
-	profanityCheck := profanityChecker(body)
-	return profanityCheck, nil
+	if in.ReplyToID != nil {
+		parent, err := cfg.db.GetChirpByID(ctx, *in.ReplyToID)
+		if err != nil || parent.HiddenAt.Valid {
+			return Chirp{}, "", newFieldError("reply_to_id", "not_found", err, "reply_to_id does not reference a chirp")
+		}
+		dbEntry.ReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+	var c Chirp
+	err = cfg.withTx(ctx, func(q *database.Queries) error {
+		chirpDB, err := q.CreateChirp(ctx, dbEntry)
+		if err == sql.ErrNoRows {
+			return errReplyBlocked
+		} else if err != nil {
+			return err
+		}
+		if within != nil {
+			if err := within(q, chirpDB); err != nil {
+				return err
+			}
+		}
+		if err := attachChirpMedia(ctx, q, chirpDB.UserID, chirpDB.ID, in.Media); err != nil {
+			return err
+		}
+		if err := flagChirp(ctx, q, chirpDB, checked); err != nil {
+			return err
+		}
+		if err := holdForSpam(ctx, q, chirpDB, spamResult, false); err != nil {
+			return err
+		}
+		mentioned, err := storeChirpEntities(ctx, q, chirpDB.UserID, chirpDB.ID, chirpDB.Body)
+		if err != nil {
+			return err
+		}
+		if c, err = hydrateChirp(ctx, q, chirpDB, uuid.NullUUID{}); err != nil {
+			return err
+		}
+		if silent {
+			return nil
+		}
+		return announceChirp(ctx, q, chirpDB, c, mentioned)
+	})
+	if err != nil {
+		return Chirp{}, "", err
+	}
+	return c, spamResult.Verdict, nil
 }
 
-func profanityChecker(msg string) string {
-	if len(msg) == 0 {
-		return ""
+// announceChirp tells live subscribers and webhooks about a new chirp and
+// notifies the users it replies to or mentions. chirp is c as everyone
+// sees it.
+func announceChirp(ctx context.Context, q *database.Queries, c database.Chirp, chirp Chirp, mentioned []database.User) error {
+	if _, err := outbox.Write(ctx, q, "chirp", c.ID, webhooks.EventChirpCreated, chirp); err != nil {
+		return err
 	}
-	words := strings.Split(msg, " ")
+	return notifyForChirp(ctx, q, c, mentioned)
+}
 
-	result := []string{}
-	for _, word := range words {
-		bwCheck := badWordCheck(word)
-		result = append(result, bwCheck)
+// chirpErrorStatus is the HTTP status for an error from postChirp. Anything
+// below 500 is the chirp's or its author's fault and won't go away by
+// retrying.
+func chirpErrorStatus(err error) int {
+	var fieldErr *FieldError
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, errAccountSuspended), errors.Is(err, errAccountDeactivated), errors.Is(err, errReplyBlocked):
+		return http.StatusForbidden
+	case errors.As(err, &validationErr), errors.As(err, &fieldErr), errors.Is(err, errMediaUnavailable):
+		return http.StatusBadRequest
 	}
-	return strings.Join(result, " ")
+	return http.StatusInternalServerError
 }
 
-func badWordCheck(word string) string {
-	badWords := []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
+func respondWithChirpError(w http.ResponseWriter, author database.User, err error) {
+	var fieldErr *FieldError
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, errAccountSuspended), errors.Is(err, errAccountDeactivated):
+		respondWithAccountError(w, author, err)
+	case errors.Is(err, errReplyBlocked):
+		respondWithError(w, http.StatusForbidden, "You can't reply to this chirp", err)
+	case errors.As(err, &validationErr), errors.As(err, &fieldErr):
+		respondWithValidationError(w, err)
+	case errors.Is(err, errMediaUnavailable):
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp in database", err)
 	}
-	for _, badWord := range badWords {
-		if strings.ToLower(word) == badWord {
-			return "****"
-		}
+}
 
+// validate_chirp checks body against the length limit and the word filter.
+// Length is counted in user-perceived characters with links at a fixed
+// weight. The returned result's Text is the body to store, with masked
+// words replaced.
+func validate_chirp(body string, maxChirpLength int, filter *moderation.Filter) (moderation.Result, *FieldError) {
+	if chirptext.IsBlank(body) {
+		return moderation.Result{}, newFieldError("body", "empty", errChirpEmpty, "chirp body can't be empty")
+	}
+	if n := chirptext.Length(body); n > maxChirpLength {
+		return moderation.Result{}, newFieldError("body", "too_long", errChirpTooLong,
+			fmt.Sprintf("chirp is %d characters, the limit is %d", n, maxChirpLength))
 	}
-	return word
+	result := filter.Check(body)
+	if result.Action() == moderation.ActionReject {
+		return moderation.Result{}, newFieldError("body", "rejected", errChirpRejected, errChirpRejected.Error())
+	}
+	return result, nil
 }
 
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
-	chirpDB, err := cfg.db.GetChirps(context.Background())
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return
+	}
+	chirpDB, err := cfg.db.GetChirps(context.Background(), viewerID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error getting chirps from database.", err)
 		return
 	}
-	Result := []Chirp{}
-	for _, c := range chirpDB {
-		conversion := Chirp{
-			ID:        c.ID,
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			User_id:   c.UserID,
-		}
-		Result = append(Result, conversion)
+	Result, err := hydrateChirps(context.Background(), cfg.db, chirpDB, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps from database.", err)
+		return
 	}
 	respondWithJSON(w, http.StatusOK, Result)
 }
@@ -139,32 +381,108 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
 		return
 	}
-	c, err := cfg.db.GetChirpByID(context.Background(), uuid.UUID(chirpID))
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return
+	}
+	c, err := cfg.db.GetVisibleChirp(context.Background(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
-	conversion := Chirp{
-		ID:        c.ID,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
-		Body:      c.Body,
-		User_id:   c.UserID,
+	chirp, err := hydrateChirp(context.Background(), cfg.db, c, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading chirp", err)
+		return
 	}
-	respondWithJSON(w, http.StatusOK, conversion)
+	respondWithJSON(w, http.StatusOK, chirp)
 }
 
-func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+func (cfg *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "no token", err)
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error decoding JSON request", err)
+		return
+	}
+	c, err := cfg.db.GetChirpByID(context.Background(), chirpID)
+	if err != nil || c.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if c.UserID != user.ID {
+		respondWithError(w, http.StatusForbidden, "user not authorized", nil)
+		return
+	}
+	if err := checkAccountActive(user); err != nil {
+		respondWithAccountError(w, user, err)
+		return
+	}
+	ent := cfg.entitlementsFor(user.ID)
+	if !ent.CanEditChirps {
+		respondWithError(w, http.StatusForbidden, "editing chirps requires Chirpy Red", nil)
+		return
+	}
+	checked, bodyErr := validate_chirp(params.Body, ent.MaxChirpLength, cfg.moderationFilter())
+	if bodyErr != nil {
+		respondWithValidationError(w, bodyErr)
+		return
+	}
+	spamResult, err := cfg.scoreSpam(context.Background(), user, checked.Text, c.ID)
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "user not authorized", err)
+		respondWithError(w, http.StatusInternalServerError, "error updating chirp", err)
+		return
+	}
+	if spamResult.Verdict == spam.Reject {
+		respondWithValidationError(w, newFieldError("body", "spam", errChirpSpam, errChirpSpam.Error()))
+		return
+	}
+	var updated database.Chirp
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		updated, err = q.UpdateChirpBody(context.Background(), database.UpdateChirpBodyParams{
+			Body: checked.Text,
+			ID:   c.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := flagChirp(context.Background(), q, updated, checked); err != nil {
+			return err
+		}
+		if err := holdForSpam(context.Background(), q, updated, spamResult, true); err != nil {
+			return err
+		}
+		_, err = storeChirpEntities(context.Background(), q, updated.UserID, updated.ID, updated.Body)
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error updating chirp", err)
+		return
+	}
+	chirp, err := hydrateChirp(context.Background(), cfg.db, updated, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading chirp", err)
 		return
 	}
+	code := http.StatusOK
+	if spamResult.Verdict == spam.Review {
+		code = http.StatusAccepted
+	}
+	respondWithJSON(w, code, chirp)
+}
+
+func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request, user database.User) {
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
@@ -175,14 +493,42 @@ func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
-	if c.UserID != userID {
+	isOwner := c.UserID == user.ID
+	if !isOwner && !auth.Role(user.Role).Includes(auth.RoleModerator) {
 		respondWithError(w, http.StatusForbidden, "user not authorized", err)
 		return
 	}
-	err = cfg.db.DeleteChirp(context.Background(), c.ID)
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if err := q.QueueChirpBlobDeletions(context.Background(), c.ID); err != nil {
+			return err
+		}
+		if err := q.DeleteChirp(context.Background(), c.ID); err != nil {
+			return err
+		}
+		deleted := map[string]any{
+			"id":      c.ID,
+			"user_id": c.UserID,
+		}
+		if c.ReplyToID.Valid {
+			deleted["reply_to_id"] = c.ReplyToID.UUID
+		}
+		_, err := outbox.Write(context.Background(), q, "chirp", c.ID, webhooks.EventChirpDeleted, deleted)
+		if err != nil || isOwner {
+			return err
+		}
+		return recordAdminAction(context.Background(), q, r, user, "chirp.delete", "chirp", c.ID.String(), "")
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error deleting chirp", err)
 		return
 	}
+	if isOwner {
+		cfg.audit.Record(context.Background(), r, audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionChirpDelete,
+			TargetType: "chirp",
+			TargetID:   c.ID.String(),
+		})
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
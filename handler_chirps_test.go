@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func TestUpdateChirpReportsValidationError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		code string
+	}{
+		{"Empty", "   ", "empty"},
+		{"Too Long", strings.Repeat("a", 561), "too_long"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user := database.User{ID: uuid.New(), AccountState: AccountActive}
+			chirpID := uuid.New()
+			mock.ExpectQuery("SELECT .* FROM chirps").WithArgs(chirpID).
+				WillReturnRows(chirpRows(user.ID, chirpID))
+			mock.ExpectQuery("SELECT plan FROM subscriptions").
+				WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("red"))
+
+			body, _ := json.Marshal(map[string]string{"body": tc.body})
+			req := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirpID.String(), strings.NewReader(string(body)))
+			req.SetPathValue("chirpID", chirpID.String())
+			rec := httptest.NewRecorder()
+			cfg.handleUpdateChirp(rec, req, user)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			var resp struct {
+				Fields []FieldError `json:"fields"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Fields) != 1 || resp.Fields[0].Code != tc.code {
+				t.Errorf("fields = %+v, want one %q error", resp.Fields, tc.code)
+			}
+		})
+	}
+}
+
+func TestHydrateChirpsUsesStoredVisibleEntities(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author, viewer, chirpID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	body := "hi @alice and @bob #Go"
+	mock.ExpectQuery("SELECT .* FROM chirp_hashtags").
+		WillReturnRows(sqlmock.NewRows([]string{"chirp_id", "tag", "start_offset", "end_offset"}).
+			AddRow(chirpID, "go", 19, 22))
+	// @bob blocked the viewer, so the query leaves that mention out.
+	mock.ExpectQuery("SELECT .* FROM chirp_mentions").
+		WithArgs(sqlmock.AnyArg(), viewer).
+		WillReturnRows(sqlmock.NewRows([]string{"chirp_id", "start_offset", "end_offset"}).
+			AddRow(chirpID, 3, 9))
+	mock.ExpectQuery("FROM attachments").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM chirp_links").WillReturnRows(sqlmock.NewRows([]string{"url"}))
+	mock.ExpectQuery("FROM bookmarks").WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+
+	chirps, err := hydrateChirps(context.Background(), cfg.db, []database.Chirp{{
+		ID:        chirpID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      body,
+		UserID:    author,
+	}}, uuid.NullUUID{UUID: viewer, Valid: true})
+	if err != nil {
+		t.Fatalf("hydrate failed: %v", err)
+	}
+	ents := chirps[0].Entities
+	if len(ents.Mentions) != 1 || ents.Mentions[0].Handle != "alice" {
+		t.Errorf("mentions = %+v, want only alice", ents.Mentions)
+	}
+	if len(ents.Hashtags) != 1 || ents.Hashtags[0].Tag != "go" || ents.Hashtags[0].Start != 19 {
+		t.Errorf("hashtags = %+v, want go at 19", ents.Hashtags)
+	}
+}
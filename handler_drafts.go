@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/spam"
+)
+
+// maxDraftLength bounds what a draft can hold. It is deliberately looser
+// than any chirp limit so people can write long and trim before posting.
+const maxDraftLength = 10000
+
+var (
+	errInvalidDraft = errors.New("invalid draft")
+	// errDraftGone rolls back a publish when the draft was deleted, or
+	// published from another device, while it was being posted.
+	errDraftGone = errors.New("draft no longer exists")
+)
+
+type Draft struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Body      string       `json:"body"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id"`
+	Media     []chirpMedia `json:"media"`
+}
+
+func draftFromDB(d database.Draft) Draft {
+	draft := Draft{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		Body:      d.Body,
+	}
+	draft.Media, _ = decodeChirpMedia(d.Media)
+	if d.ReplyToID.Valid {
+		draft.ReplyToID = &d.ReplyToID.UUID
+	}
+	return draft
+}
+
+type draftParameters struct {
+	Body      string       `json:"body"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id"`
+	Media     []chirpMedia `json:"media"`
+	// IfUpdatedAt is the updated_at of the copy being edited. When set, the
+	// update is refused if the draft has been saved since.
+	IfUpdatedAt *time.Time `json:"if_updated_at"`
+}
+
+// decodeDraft reads a draft body, responding 400 when it isn't valid JSON.
+func decodeDraft(w http.ResponseWriter, r *http.Request) (draftParameters, bool) {
+	params := draftParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return params, false
+	}
+	return params, true
+}
+
+// checkDraft checks a draft and encodes its media. Only sizes are checked
+// here; the chirp rules apply when the draft is published.
+func (cfg *apiConfig) checkDraft(params draftParameters) (json.RawMessage, error) {
+	var bodyErr *FieldError
+	if utf8.RuneCountInString(params.Body) > maxDraftLength {
+		bodyErr = newFieldError("body", "too_long", errInvalidDraft,
+			fmt.Sprintf("drafts are limited to %d characters", maxDraftLength))
+	}
+	if err := validationErrors(bodyErr, cfg.validateChirpMedia(params.Media)); err != nil {
+		return nil, err
+	}
+	return encodeChirpMedia(params.Media)
+}
+
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+func (cfg *apiConfig) handleCreateDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, ok := decodeDraft(w, r)
+	if !ok {
+		return
+	}
+	media, err := cfg.checkDraft(params)
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	draft, err := cfg.db.CreateDraft(context.Background(), database.CreateDraftParams{
+		UserID:    user.ID,
+		Body:      params.Body,
+		ReplyToID: nullUUID(params.ReplyToID),
+		Media:     media,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error saving draft", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, draftFromDB(draft))
+}
+
+func (cfg *apiConfig) handleListDrafts(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset := parsePagination(r)
+	drafts, err := cfg.db.ListDrafts(context.Background(), database.ListDraftsParams{
+		UserID: user.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing drafts", err)
+		return
+	}
+	result := []Draft{}
+	for _, d := range drafts {
+		result = append(result, draftFromDB(d))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// getOwnDraft loads the {draftID} of the request, treating other users'
+// drafts as missing.
+func (cfg *apiConfig) getOwnDraft(w http.ResponseWriter, r *http.Request, user database.User) (database.Draft, bool) {
+	id, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return database.Draft{}, false
+	}
+	draft, err := cfg.db.GetDraft(context.Background(), id)
+	if err == sql.ErrNoRows || (err == nil && draft.UserID != user.ID) {
+		respondWithError(w, http.StatusNotFound, "Draft not found", err)
+		return database.Draft{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error looking up draft", err)
+		return database.Draft{}, false
+	}
+	return draft, true
+}
+
+func (cfg *apiConfig) handleUpdateDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draft, ok := cfg.getOwnDraft(w, r, user)
+	if !ok {
+		return
+	}
+	params, ok := decodeDraft(w, r)
+	if !ok {
+		return
+	}
+	media, err := cfg.checkDraft(params)
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	update := database.UpdateDraftParams{
+		Body:      params.Body,
+		ReplyToID: nullUUID(params.ReplyToID),
+		Media:     media,
+		ID:        draft.ID,
+		UserID:    user.ID,
+	}
+	if params.IfUpdatedAt != nil {
+		update.IfUpdatedAt = sql.NullTime{Time: params.IfUpdatedAt.UTC(), Valid: true}
+	}
+	updated, err := cfg.db.UpdateDraft(context.Background(), update)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Draft was changed or deleted on another device", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error saving draft", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, draftFromDB(updated))
+}
+
+func (cfg *apiConfig) handleDeleteDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	n, err := cfg.db.DeleteDraft(context.Background(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting draft", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePublishDraft posts a draft as a chirp. The chirp is created and
+// the draft deleted in one transaction, so a draft published from two
+// devices at once yields one chirp, and a chirp that fails validation
+// leaves the draft as it was.
+func (cfg *apiConfig) handlePublishDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draft, ok := cfg.getOwnDraft(w, r, user)
+	if !ok {
+		return
+	}
+	media, err := decodeChirpMedia(draft.Media)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error reading draft", err)
+		return
+	}
+	in := newChirp{Body: draft.Body, Media: media}
+	if draft.ReplyToID.Valid {
+		in.ReplyToID = &draft.ReplyToID.UUID
+	}
+	c, verdict, err := cfg.postChirp(context.Background(), user, in, func(q *database.Queries, _ database.Chirp) error {
+		n, err := q.DeleteDraft(context.Background(), database.DeleteDraftParams{
+			ID:     draft.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errDraftGone
+		}
+		return nil
+	})
+	if errors.Is(err, errDraftGone) {
+		respondWithError(w, http.StatusNotFound, "Draft not found", err)
+		return
+	} else if err != nil {
+		respondWithChirpError(w, user, err)
+		return
+	}
+	code := http.StatusCreated
+	if verdict == spam.Review {
+		code = http.StatusAccepted
+	}
+	respondWithJSON(w, code, c)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+var draftColumns = []string{"id", "created_at", "updated_at", "user_id", "body", "reply_to_id", "media"}
+
+func draftRow(id, user uuid.UUID) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(draftColumns).AddRow(id, now, now, user, "hello", nil, []byte(`[]`))
+}
+
+func newDraftRequest(method string, draftID uuid.UUID, body string) *http.Request {
+	req := httptest.NewRequest(method, "/api/drafts/"+draftID.String(), strings.NewReader(body))
+	req.SetPathValue("draftID", draftID.String())
+	return req
+}
+
+func TestUpdateDraftRejectsInvalidJSON(t *testing.T) {
+	for _, body := range []string{``, `{"body":`, `{"body": 5}`} {
+		cfg, mock := newTestConfig(t)
+		user, id := uuid.New(), uuid.New()
+		mock.ExpectQuery("SELECT .* FROM drafts").WithArgs(id).WillReturnRows(draftRow(id, user))
+
+		rec := httptest.NewRecorder()
+		cfg.handleUpdateDraft(rec, newDraftRequest(http.MethodPut, id, body), database.User{ID: user})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %q: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUpdateDraftSavedElsewhere(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user, id := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM drafts").WithArgs(id).WillReturnRows(draftRow(id, user))
+	mock.ExpectQuery("UPDATE drafts").WillReturnRows(sqlmock.NewRows(draftColumns))
+
+	body := `{"body":"hello again","if_updated_at":"2026-01-02T03:04:05Z"}`
+	rec := httptest.NewRecorder()
+	cfg.handleUpdateDraft(rec, newDraftRequest(http.MethodPut, id, body), database.User{ID: user})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
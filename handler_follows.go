@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handleFollowUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	if followeeID == user.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), followeeID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error looking up user", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		n, err := q.FollowUser(context.Background(), database.FollowUserParams{
+			FollowerID: user.ID,
+			FolloweeID: followeeID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Either already following or blocked; only the latter is an
+			// error.
+			blocked, err := q.IsBlockedBetween(context.Background(), database.IsBlockedBetweenParams{
+				UserA: user.ID,
+				UserB: followeeID,
+			})
+			if err == nil && blocked {
+				return errFollowBlocked
+			}
+			return err
+		}
+		return notify(context.Background(), q, followeeID, user.ID, NotificationFollow, uuid.NullUUID{})
+	})
+	if errors.Is(err, errFollowBlocked) {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	err = cfg.db.UnfollowUser(context.Background(), database.UnfollowUserParams{
+		FollowerID: user.ID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unfollowing user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
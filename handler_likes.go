@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	c, err := cfg.db.GetVisibleChirp(context.Background(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		n, err := q.LikeChirp(context.Background(), database.LikeChirpParams{
+			UserID:  user.ID,
+			ChirpID: c.ID,
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		return notify(context.Background(), q, c.UserID, user.ID, NotificationLike, uuid.NullUUID{UUID: c.ID, Valid: true})
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error liking chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	_, err = cfg.db.UnlikeChirp(context.Background(), database.UnlikeChirpParams{
+		UserID:  user.ID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unliking chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/blob"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/media"
+)
+
+const (
+	maxAltTextLength       = 1000
+	mediaSweepInterval     = time.Minute
+	blobDeletionBatch      = 100
+	blobDeletionLease      = 5 * time.Minute
+	maxBlobDeletionBackoff = time.Hour
+)
+
+var errMediaUnavailable = errors.New("media not found or already attached")
+
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	SizeBytes    int64     `json:"size_bytes"`
+	AltText      string    `json:"alt_text"`
+}
+
+func attachmentFromDB(a database.Attachment) Attachment {
+	return Attachment{
+		ID:           a.ID,
+		URL:          "/api/media/" + a.ID.String(),
+		ThumbnailURL: "/api/media/" + a.ID.String() + "/thumbnail",
+		ContentType:  a.ContentType,
+		Width:        a.Width,
+		Height:       a.Height,
+		SizeBytes:    a.SizeBytes,
+		AltText:      a.AltText,
+	}
+}
+
+func thumbnailContentType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func (cfg *apiConfig) handleUploadMedia(w http.ResponseWriter, r *http.Request, user database.User) {
+	// Leave headroom for the multipart envelope and the alt_text field.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.maxMediaBytes+64<<10)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload too large or malformed", err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Missing file field", err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, cfg.maxMediaBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading upload", err)
+		return
+	}
+	if int64(len(data)) > cfg.maxMediaBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Files are limited to %d bytes", cfg.maxMediaBytes), nil)
+		return
+	}
+	altText := r.FormValue("alt_text")
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		respondWithError(w, http.StatusBadRequest, "alt_text is too long", nil)
+		return
+	}
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported", err)
+		return
+	} else if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Image dimensions are too large", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode image", err)
+		return
+	}
+
+	id := uuid.New()
+	blobKey := "media/" + id.String() + "/original"
+	thumbKey := "media/" + id.String() + "/thumbnail"
+	for _, b := range []struct {
+		key string
+		img media.Image
+	}{{blobKey, processed.Original}, {thumbKey, processed.Thumbnail}} {
+		if err := cfg.blobs.Put(r.Context(), b.key, bytes.NewReader(b.img.Data), b.img.ContentType); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error storing media", err)
+			return
+		}
+	}
+	a, err := cfg.db.CreateAttachment(context.Background(), database.CreateAttachmentParams{
+		ID:           id,
+		OwnerID:      user.ID,
+		ContentType:  processed.Original.ContentType,
+		SizeBytes:    int64(len(processed.Original.Data)),
+		Width:        int32(processed.Original.Width),
+		Height:       int32(processed.Original.Height),
+		BlobKey:      blobKey,
+		ThumbnailKey: thumbKey,
+		AltText:      altText,
+	})
+	if err != nil {
+		cfg.deleteBlobs(blobKey, thumbKey)
+		respondWithError(w, http.StatusInternalServerError, "Error saving media", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, attachmentFromDB(a))
+}
+
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	a, err := cfg.db.GetAttachment(context.Background(), mediaID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Media not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error looking up media", err)
+		return
+	}
+	if a.ChirpID.Valid {
+		c, err := cfg.db.GetChirpByID(context.Background(), a.ChirpID.UUID)
+		if err != nil || c.HiddenAt.Valid {
+			respondWithError(w, http.StatusNotFound, "Media not found", err)
+			return
+		}
+	}
+	key, contentType := a.BlobKey, a.ContentType
+	if thumbnail {
+		key, contentType = a.ThumbnailKey, thumbnailContentType(a.ContentType)
+	}
+	rc, err := cfg.blobs.Get(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Media not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reading media", err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Media is immutable once uploaded, so clients may cache it forever.
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if !thumbnail {
+		w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	}
+	io.Copy(w, rc)
+}
+
+func (cfg *apiConfig) handleGetMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *apiConfig) handleGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+type chirpMedia struct {
+	ID      uuid.UUID `json:"id"`
+	AltText *string   `json:"alt_text"`
+}
+
+// encodeChirpMedia and decodeChirpMedia keep the attachments of a chirp
+// that isn't posted yet in a JSONB column.
+func encodeChirpMedia(items []chirpMedia) (json.RawMessage, error) {
+	if items == nil {
+		items = []chirpMedia{}
+	}
+	return json.Marshal(items)
+}
+
+func decodeChirpMedia(raw json.RawMessage) ([]chirpMedia, error) {
+	items := []chirpMedia{}
+	err := json.Unmarshal(raw, &items)
+	return items, err
+}
+
+// attachChirpMedia attaches the caller's uploads to a new chirp in order.
+// Alt text given here replaces any set at upload time.
+func attachChirpMedia(ctx context.Context, q *database.Queries, owner, chirpID uuid.UUID, items []chirpMedia) error {
+	for i, item := range items {
+		a, err := q.GetAttachment(ctx, item.ID)
+		if err == sql.ErrNoRows || (err == nil && (a.OwnerID != owner || a.ChirpID.Valid)) {
+			return fmt.Errorf("%w: %s", errMediaUnavailable, item.ID)
+		} else if err != nil {
+			return err
+		}
+		altText := a.AltText
+		if item.AltText != nil {
+			altText = *item.AltText
+		}
+		n, err := q.AttachToChirp(ctx, database.AttachToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpID, Valid: true},
+			Position: int32(i),
+			AltText:  altText,
+			ID:       a.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", errMediaUnavailable, item.ID)
+		}
+	}
+	return nil
+}
+
+// validateChirpMedia checks the media list on a chirp before any writes.
+func (cfg *apiConfig) validateChirpMedia(items []chirpMedia) *FieldError {
+	if len(items) > cfg.maxChirpMedia {
+		return newFieldError("media", "too_many", errInvalidMedia,
+			fmt.Sprintf("a chirp can have at most %d attachments", cfg.maxChirpMedia))
+	}
+	seen := map[uuid.UUID]bool{}
+	for i, item := range items {
+		if seen[item.ID] {
+			return newFieldError(fmt.Sprintf("media[%d].id", i), "duplicate", errInvalidMedia,
+				fmt.Sprintf("media %s is listed twice", item.ID))
+		}
+		seen[item.ID] = true
+		if item.AltText != nil && utf8.RuneCountInString(*item.AltText) > maxAltTextLength {
+			return newFieldError(fmt.Sprintf("media[%d].alt_text", i), "too_long", errInvalidMedia,
+				fmt.Sprintf("alt_text is limited to %d characters", maxAltTextLength))
+		}
+	}
+	return nil
+}
+
+// deleteBlobs removes the blobs of an upload whose row couldn't be saved,
+// logging rather than failing since nothing references them.
+func (cfg *apiConfig) deleteBlobs(keys ...string) {
+	for _, key := range keys {
+		if err := cfg.blobs.Delete(context.Background(), key); err != nil {
+			log.Printf("error deleting blob %s: %s", key, err)
+		}
+	}
+}
+
+// runMediaSweeper deletes uploads left unattached for longer than
+// cfg.unattachedTTL and works through the queue of blobs whose rows
+// were deleted.
+func (cfg *apiConfig) runMediaSweeper(ctx context.Context) {
+	ticker := time.NewTicker(mediaSweepInterval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.sweepUnattachedMedia(ctx); err != nil {
+			log.Printf("media sweeper: sweeping unattached media: %s", err)
+		}
+		cfg.deleteQueuedBlobs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) sweepUnattachedMedia(ctx context.Context) error {
+	return cfg.withTx(ctx, func(q *database.Queries) error {
+		swept, err := q.DeleteUnattachedMedia(ctx, cfg.unattachedTTL.Seconds())
+		if err != nil || len(swept) == 0 {
+			return err
+		}
+		keys := make([]string, 0, 2*len(swept))
+		for _, a := range swept {
+			keys = append(keys, a.BlobKey, a.ThumbnailKey)
+		}
+		return q.QueueBlobDeletions(ctx, keys)
+	})
+}
+
+// deleteQueuedBlobs deletes one batch of queued blobs, backing off blobs
+// the store fails to delete.
+func (cfg *apiConfig) deleteQueuedBlobs(ctx context.Context) {
+	due, err := cfg.db.ClaimBlobDeletions(ctx, database.ClaimBlobDeletionsParams{
+		LeaseSeconds: blobDeletionLease.Seconds(),
+		Limit:        blobDeletionBatch,
+	})
+	if err != nil {
+		log.Printf("media sweeper: claiming blob deletions: %s", err)
+		return
+	}
+	for _, d := range due {
+		if err := cfg.blobs.Delete(ctx, d.Key); err != nil {
+			backoff := min(time.Duration(d.Attempts+1)*time.Duration(d.Attempts+1)*time.Minute, maxBlobDeletionBackoff)
+			err = cfg.db.FailBlobDeletion(ctx, database.FailBlobDeletionParams{
+				LastError:      err.Error(),
+				BackoffSeconds: backoff.Seconds(),
+				Key:            d.Key,
+			})
+		} else {
+			err = cfg.db.CompleteBlobDeletion(ctx, d.Key)
+		}
+		if err != nil {
+			log.Printf("media sweeper: recording deletion of %s: %s", d.Key, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var attachmentColumns = []string{"id", "owner_id", "chirp_id", "position", "content_type", "size_bytes", "width", "height", "blob_key", "thumbnail_key", "alt_text", "created_at"}
+
+// fakeBlobs records deletes and fails those of keys in failing.
+type fakeBlobs struct {
+	failing map[string]bool
+	deleted []string
+}
+
+func (b *fakeBlobs) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	return nil
+}
+
+func (b *fakeBlobs) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (b *fakeBlobs) Delete(ctx context.Context, key string) error {
+	if b.failing[key] {
+		return errors.New("store unavailable")
+	}
+	b.deleted = append(b.deleted, key)
+	return nil
+}
+
+func TestSweepUnattachedMediaQueuesBlobs(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.unattachedTTL = time.Hour
+	id := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM attachments\\s+WHERE chirp_id IS NULL").
+		WithArgs(float64(3600)).
+		WillReturnRows(sqlmock.NewRows(attachmentColumns).
+			AddRow(id, uuid.New(), nil, 0, "image/png", 10, 1, 1, "media/x/original", "media/x/thumbnail", "", time.Now()))
+	mock.ExpectExec("INSERT INTO blob_deletions").
+		WithArgs(`{"media/x/original","media/x/thumbnail"}`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := cfg.sweepUnattachedMedia(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+}
+
+func TestSweepUnattachedMediaKeepsDraftAndScheduledMedia(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.unattachedTTL = time.Hour
+	mock.ExpectBegin()
+	// Uploads a draft or a pending scheduled chirp still lists are left
+	// out of the delete, so with nothing else expired nothing is queued.
+	mock.ExpectQuery("DELETE FROM attachments\\s+WHERE chirp_id IS NULL.*" +
+		"NOT EXISTS \\(\\s+SELECT 1 FROM drafts\\s+WHERE drafts.media @> .*attachments.id.*" +
+		"NOT EXISTS \\(\\s+SELECT 1 FROM scheduled_chirps\\s+WHERE scheduled_chirps.state = 'pending'\\s+" +
+		"AND scheduled_chirps.media @> .*attachments.id").
+		WithArgs(float64(3600)).
+		WillReturnRows(sqlmock.NewRows(attachmentColumns))
+	mock.ExpectCommit()
+
+	if err := cfg.sweepUnattachedMedia(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+}
+
+func TestDeleteQueuedBlobsBacksOffFailures(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	blobs := &fakeBlobs{failing: map[string]bool{"media/b/original": true}}
+	cfg.blobs = blobs
+	now := time.Now()
+	mock.ExpectQuery("UPDATE blob_deletions\\s+SET next_attempt_at").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "attempts", "next_attempt_at", "last_error", "created_at"}).
+			AddRow("media/a/original", 0, now, "", now).
+			AddRow("media/b/original", 1, now, "", now))
+	mock.ExpectExec("DELETE FROM blob_deletions").
+		WithArgs("media/a/original").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE blob_deletions\\s+SET attempts").
+		WithArgs("store unavailable", float64(240), "media/b/original").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cfg.deleteQueuedBlobs(context.Background())
+	if len(blobs.deleted) != 1 || blobs.deleted[0] != "media/a/original" {
+		t.Errorf("deleted %v, want only media/a/original", blobs.deleted)
+	}
+}
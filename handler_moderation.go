@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/audit"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+)
+
+const moderationReloadInterval = time.Minute
+
+// moderationFilter returns the current word filter. It is swapped out as a
+// whole whenever the word list changes.
+func (cfg *apiConfig) moderationFilter() *moderation.Filter {
+	return cfg.moderation.Load()
+}
+
+// reloadModeration rebuilds the filter from the configured rules plus the
+// words stored in the database, which take precedence.
+func (cfg *apiConfig) reloadModeration(ctx context.Context) error {
+	words, err := cfg.db.ListModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+	rules := append([]moderation.Rule{}, cfg.moderationRules...)
+	for _, w := range words {
+		rules = append(rules, moderation.Rule{Word: w.Word, Action: moderation.Action(w.Action)})
+	}
+	filter, err := moderation.NewFilter(rules)
+	if err != nil {
+		return err
+	}
+	cfg.moderation.Store(filter)
+	return nil
+}
+
+// watchModerationWords periodically picks up word list changes made
+// through other instances.
+func (cfg *apiConfig) watchModerationWords(ctx context.Context) {
+	ticker := time.NewTicker(moderationReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.reloadModeration(ctx); err != nil {
+				log.Printf("error reloading moderation words: %s", err)
+			}
+		}
+	}
+}
+
+type ModerationWord struct {
+	Word   string            `json:"word"`
+	Action moderation.Action `json:"action"`
+	Source string            `json:"source"`
+}
+
+func (cfg *apiConfig) handleListModerationWords(w http.ResponseWriter, r *http.Request, admin database.User) {
+	words, err := cfg.db.ListModerationWords(context.Background())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing moderation words", err)
+		return
+	}
+	result := []ModerationWord{}
+	for _, rule := range cfg.moderationRules {
+		result = append(result, ModerationWord{Word: rule.Word, Action: rule.Action, Source: "config"})
+	}
+	for _, word := range words {
+		result = append(result, ModerationWord{Word: word.Word, Action: moderation.Action(word.Action), Source: "database"})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleSetModerationWord(w http.ResponseWriter, r *http.Request, admin database.User) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "action must be mask, flag or reject", err)
+		return
+	}
+	rule := moderation.Rule{Word: moderation.Fold(r.PathValue("word")), Action: action}
+	if _, err := moderation.NewFilter([]moderation.Rule{rule}); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	word, err := cfg.db.UpsertModerationWord(context.Background(), database.UpsertModerationWordParams{
+		Word:   rule.Word,
+		Action: string(rule.Action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error saving moderation word", err)
+		return
+	}
+	if err := cfg.reloadModeration(context.Background()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error reloading moderation words", err)
+		return
+	}
+	cfg.audit.Record(context.Background(), r, audit.Event{
+		ActorID:    admin.ID,
+		Action:     "admin.moderation_word.set",
+		TargetType: "moderation_word",
+		TargetID:   word.Word,
+		Metadata:   map[string]any{"action": word.Action},
+	})
+	respondWithJSON(w, http.StatusOK, ModerationWord{Word: word.Word, Action: moderation.Action(word.Action), Source: "database"})
+}
+
+func (cfg *apiConfig) handleDeleteModerationWord(w http.ResponseWriter, r *http.Request, admin database.User) {
+	word := moderation.Fold(r.PathValue("word"))
+	n, err := cfg.db.DeleteModerationWord(context.Background(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting moderation word", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Moderation word not found", nil)
+		return
+	}
+	if err := cfg.reloadModeration(context.Background()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error reloading moderation words", err)
+		return
+	}
+	cfg.audit.Record(context.Background(), r, audit.Event{
+		ActorID:    admin.ID,
+		Action:     "admin.moderation_word.delete",
+		TargetType: "moderation_word",
+		TargetID:   word,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChirpFlag struct {
+	ID           uuid.UUID `json:"id"`
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	MatchedWords []string  `json:"matched_words"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// handleListChirpFlags lists chirps the word filter flagged that are still
+// waiting in the report queue. Resolving the report clears the flag.
+func (cfg *apiConfig) handleListChirpFlags(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	limit, offset := parsePagination(r)
+	reports, err := cfg.db.ListPendingSystemReports(context.Background(), database.ListPendingSystemReportsParams{
+		Reason: ReasonFilteredLanguage,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing flagged chirps", err)
+		return
+	}
+	result := []ChirpFlag{}
+	for _, report := range reports {
+		result = append(result, ChirpFlag{
+			ID:           report.ID,
+			ChirpID:      report.ChirpID.UUID,
+			MatchedWords: strings.Split(strings.TrimPrefix(report.Details, matchedPrefix), ", "),
+			CreatedAt:    report.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
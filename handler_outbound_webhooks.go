@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/netguard"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID  `json:"id"`
+	OwnerID   *uuid.UUID `json:"owner_id"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	Secret    string     `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func webhookEndpointFromDB(e database.WebhookEndpoint) WebhookEndpoint {
+	endpoint := WebhookEndpoint{
+		ID:        e.ID,
+		URL:       e.Url,
+		Events:    e.Events,
+		Active:    e.Active,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.OwnerID.Valid {
+		endpoint.OwnerID = &e.OwnerID.UUID
+	}
+	return endpoint
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" || u.User != nil {
+		respondWithError(w, http.StatusBadRequest, "url must be an absolute http or https URL", err)
+		return
+	}
+	// Deliveries can only reach public addresses anyway; refusing others
+	// up front tells the user why their endpoint would never work.
+	if err := netguard.CheckHost(r.Context(), u.Hostname()); errors.Is(err, netguard.ErrBlockedAddress) {
+		respondWithError(w, http.StatusBadRequest, "url must point to a public address", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't resolve url host", err)
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one event is required", nil)
+		return
+	}
+	for _, event := range params.Events {
+		if !webhooks.IsSupportedEvent(event) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unsupported event %q", event), nil)
+			return
+		}
+	}
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating secret", err)
+		return
+	}
+	endpointDB, err := cfg.db.CreateWebhookEndpoint(context.Background(), database.CreateWebhookEndpointParams{
+		OwnerID: owner,
+		Url:     u.String(),
+		Secret:  secret,
+		Events:  params.Events,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating webhook endpoint", err)
+		return
+	}
+	endpoint := webhookEndpointFromDB(endpointDB)
+	// The secret is only ever shown once, at creation.
+	endpoint.Secret = endpointDB.Secret
+	respondWithJSON(w, http.StatusCreated, endpoint)
+}
+
+func (cfg *apiConfig) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) {
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{UUID: user.ID, Valid: true})
+}
+
+func (cfg *apiConfig) handleAdminCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request, admin database.User) {
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handleListWebhookEndpoints(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpoints, err := cfg.db.ListWebhookEndpointsForOwner(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing webhook endpoints", err)
+		return
+	}
+	result := []WebhookEndpoint{}
+	for _, e := range endpoints {
+		result = append(result, webhookEndpointFromDB(e))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handleAdminListWebhookEndpoints(w http.ResponseWriter, r *http.Request, admin database.User) {
+	endpoints, err := cfg.db.ListGlobalWebhookEndpoints(context.Background())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing webhook endpoints", err)
+		return
+	}
+	result := []WebhookEndpoint{}
+	for _, e := range endpoints {
+		result = append(result, webhookEndpointFromDB(e))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// webhookEndpointForUser loads the endpoint named in the path if user owns
+// it. Admins may manage any endpoint, including global ones.
+func (cfg *apiConfig) webhookEndpointForUser(w http.ResponseWriter, r *http.Request, user database.User) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.db.GetWebhookEndpoint(context.Background(), endpointID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", err)
+		return database.WebhookEndpoint{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading webhook endpoint", err)
+		return database.WebhookEndpoint{}, false
+	}
+	isOwner := endpoint.OwnerID.Valid && endpoint.OwnerID.UUID == user.ID
+	if !isOwner && !auth.Role(user.Role).Includes(auth.RoleAdmin) {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpoint, ok := cfg.webhookEndpointForUser(w, r, user)
+	if !ok {
+		return
+	}
+	if err := cfg.db.DeleteWebhookEndpoint(context.Background(), endpoint.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting webhook endpoint", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpoint, ok := cfg.webhookEndpointForUser(w, r, user)
+	if !ok {
+		return
+	}
+	limit, offset := parsePagination(r)
+	deliveries, err := cfg.db.ListWebhookDeliveries(context.Background(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing webhook deliveries", err)
+		return
+	}
+	result := []WebhookDelivery{}
+	for _, d := range deliveries {
+		delivery := WebhookDelivery{
+			ID:            d.ID,
+			EventType:     d.EventType,
+			Payload:       d.Payload,
+			Status:        d.Status,
+			Attempts:      d.Attempts,
+			NextAttemptAt: d.NextAttemptAt,
+			LastError:     d.LastError,
+			CreatedAt:     d.CreatedAt,
+		}
+		if d.LastStatusCode.Valid {
+			delivery.LastStatusCode = &d.LastStatusCode.Int32
+		}
+		if d.DeliveredAt.Valid {
+			delivery.DeliveredAt = &d.DeliveredAt.Time
+		}
+		result = append(result, delivery)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+var (
+	errTooManyPins = errors.New("pin limit reached")
+	errInvalidPin  = errors.New("invalid pin")
+)
+
+// getOwnChirpToPin loads the {chirpID} of the request, which must be one
+// of user's own chirps.
+func (cfg *apiConfig) getOwnChirpToPin(w http.ResponseWriter, r *http.Request, user database.User) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return database.Chirp{}, false
+	}
+	c, err := cfg.db.GetChirpByID(context.Background(), chirpID)
+	if err != nil || c.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return database.Chirp{}, false
+	}
+	if c.UserID != user.ID {
+		respondWithError(w, http.StatusForbidden, "you can only pin your own chirps", nil)
+		return database.Chirp{}, false
+	}
+	return c, true
+}
+
+// handlePinChirp pins one of the user's chirps to the top of their
+// profile. Pinning a chirp that is already pinned changes nothing.
+func (cfg *apiConfig) handlePinChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	c, ok := cfg.getOwnChirpToPin(w, r, user)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if err := q.LockUserPins(context.Background(), user.ID); err != nil {
+			return err
+		}
+		pinned, err := q.ListPinnedChirpIDs(context.Background(), user.ID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(pinned, c.ID) {
+			return nil
+		}
+		if len(pinned) >= cfg.maxPinned {
+			return errTooManyPins
+		}
+		return q.PinChirp(context.Background(), database.PinChirpParams{
+			UserID:  user.ID,
+			ChirpID: c.ID,
+		})
+	})
+	if errors.Is(err, errTooManyPins) {
+		respondWithError(w, http.StatusConflict,
+			fmt.Sprintf("you can pin at most %d chirps, unpin one first", cfg.maxPinned), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error pinning chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnpinChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	_, err = cfg.db.UnpinChirp(context.Background(), database.UnpinChirpParams{
+		UserID:  user.ID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unpinning chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMovePin moves a pinned chirp to position among the user's pins.
+// Position 0 is the top; positions past the end put it last.
+func (cfg *apiConfig) handleMovePin(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	type parameters struct {
+		Position int32 `json:"position"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Position < 0 {
+		respondWithValidationError(w, newFieldError("position", "negative", errInvalidPin, "position can't be negative"))
+		return
+	}
+	err = cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if err := q.LockUserPins(context.Background(), user.ID); err != nil {
+			return err
+		}
+		err := q.MakeRoomForPin(context.Background(), database.MakeRoomForPinParams{
+			Index:   params.Position,
+			UserID:  user.ID,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			return err
+		}
+		moved, err := q.MovePin(context.Background(), database.MovePinParams{
+			Index:   params.Position,
+			UserID:  user.ID,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			return err
+		}
+		if moved == 0 {
+			// Nothing to move, so don't keep the renumbering either.
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp isn't pinned", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moving pin", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetUserChirps lists a user's chirps newest first. The first page
+// starts with their pinned chirps, marked pinned, which are left out of
+// the rest of the listing so paging never shows them twice.
+func (cfg *apiConfig) handleGetUserChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), userID); err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+	limit, offset := parsePagination(r)
+	var chirpsDB []database.Chirp
+	if offset == 0 {
+		chirpsDB, err = cfg.db.ListPinnedChirps(context.Background(), database.ListPinnedChirpsParams{
+			UserID:   userID,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+			return
+		}
+	}
+	pinned := len(chirpsDB)
+	rest, err := cfg.db.ListUserChirps(context.Background(), database.ListUserChirpsParams{
+		UserID:   userID,
+		ViewerID: viewerID,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+	result, err := hydrateChirps(context.Background(), cfg.db, append(chirpsDB, rest...), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+	for i := range pinned {
+		result[i].Pinned = true
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
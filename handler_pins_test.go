@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func newPinRequest(method string, chirpID uuid.UUID, body string) *http.Request {
+	req := httptest.NewRequest(method, "/api/chirps/"+chirpID.String()+"/pin", strings.NewReader(body))
+	req.SetPathValue("chirpID", chirpID.String())
+	return req
+}
+
+func TestPinChirpLimit(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		pinned int
+		want   int
+	}{
+		{"Under The Limit", 1, http.StatusNoContent},
+		{"At The Limit", 2, http.StatusConflict},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			cfg.maxPinned = 2
+			user, chirpID := uuid.New(), uuid.New()
+			mock.ExpectQuery("SELECT .* FROM chirps").WithArgs(chirpID).
+				WillReturnRows(chirpRows(user, chirpID))
+			mock.ExpectBegin()
+			mock.ExpectExec("SELECT id FROM users\\s+WHERE id = \\$1\\s+FOR UPDATE").WithArgs(user).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			// Pins on hidden chirps aren't counted.
+			pinned := sqlmock.NewRows([]string{"chirp_id"})
+			for range tc.pinned {
+				pinned.AddRow(uuid.New())
+			}
+			mock.ExpectQuery("SELECT pinned_chirps.chirp_id FROM pinned_chirps\\s+JOIN chirps .*chirps.hidden_at IS NULL").
+				WithArgs(user).WillReturnRows(pinned)
+			if tc.want == http.StatusNoContent {
+				mock.ExpectExec("INSERT INTO pinned_chirps").WithArgs(user, chirpID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handlePinChirp(rec, newPinRequest(http.MethodPost, chirpID, ""), database.User{ID: user})
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMovePin(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		moved int64
+		want  int
+	}{
+		{"Pinned", 1, http.StatusNoContent},
+		// The renumbering of the other pins is rolled back.
+		{"Not Pinned", 0, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user, chirpID := uuid.New(), uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectExec("FOR UPDATE").WithArgs(user).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("UPDATE pinned_chirps\\s+SET position = ordered.idx").
+				WithArgs(int32(1), user, chirpID).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectExec("UPDATE pinned_chirps\\s+SET position = \\$1::int").
+				WithArgs(int32(1), user, chirpID).
+				WillReturnResult(sqlmock.NewResult(0, tc.moved))
+			if tc.moved == 0 {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handleMovePin(rec, newPinRequest(http.MethodPut, chirpID, `{"position":1}`), database.User{ID: user})
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMovePinNegativePosition(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	rec := httptest.NewRecorder()
+	cfg.handleMovePin(rec, newPinRequest(http.MethodPut, uuid.New(), `{"position":-1}`), database.User{ID: uuid.New()})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var body struct {
+		Fields []FieldError `json:"fields"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Fields) != 1 || body.Fields[0].Field != "position" || body.Fields[0].Code != "negative" {
+		t.Errorf("fields = %+v, want one negative position", body.Fields)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetUserChirpsPutsPinsFirst(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author, pinnedID, otherID := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(author).WillReturnRows(userRow(author))
+	// Signed out, so only chirps anyone can see are listed.
+	mock.ExpectQuery("FROM pinned_chirps\\s+JOIN chirps .*chirps.hidden_at IS NULL").
+		WithArgs(author, nil).
+		WillReturnRows(chirpRows(author, pinnedID))
+	mock.ExpectQuery("SELECT .* FROM chirps\\s+WHERE user_id = \\$1::uuid\\s+AND hidden_at IS NULL").
+		WithArgs(author, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(chirpRows(author, otherID))
+	mock.ExpectQuery("FROM chirp_hashtags").WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	mock.ExpectQuery("FROM chirp_mentions").WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	mock.ExpectQuery("FROM attachments").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM chirp_links").WillReturnRows(sqlmock.NewRows([]string{"url"}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/"+author.String()+"/chirps", nil)
+	req.SetPathValue("userID", author.String())
+	rec := httptest.NewRecorder()
+	cfg.handleGetUserChirps(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got []Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != pinnedID || !got[0].Pinned || got[1].ID != otherID || got[1].Pinned {
+		t.Errorf("chirps = %+v, want the pinned chirp first and marked", got)
+	}
+}
+
+func TestGetUserChirpsLeavesPinsOffLaterPages(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(author).WillReturnRows(userRow(author))
+	mock.ExpectQuery("SELECT .* FROM chirps\\s+WHERE user_id = \\$1::uuid").
+		WithArgs(author, nil, int32(defaultPageLimit), int32(20)).
+		WillReturnRows(sqlmock.NewRows(chirpColumns))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/"+author.String()+"/chirps?offset=20", nil)
+	req.SetPathValue("userID", author.String())
+	rec := httptest.NewRecorder()
+	cfg.handleGetUserChirps(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
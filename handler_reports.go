@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportClaimed   = "claimed"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+
+	ResolutionHideChirp = "hide_chirp"
+	ResolutionWarn      = "warn"
+	ResolutionSuspend   = "suspend"
+	ResolutionDismiss   = "dismiss"
+
+	ReasonFilteredLanguage = "filtered_language"
+	ReasonSpam             = "spam"
+
+	// matchedPrefix starts the details of filtered_language reports, which
+	// list the words that matched.
+	matchedPrefix = "matched: "
+
+	maxReportDetailsLength = 1000
+	defaultSuspension      = 7 * 24 * time.Hour
+	maxSuspension          = 365 * 24 * time.Hour
+)
+
+// reportReasons are the reasons users may give. filtered_language is
+// reserved for reports raised by the word filter.
+var reportReasons = map[string]bool{
+	"spam":          true,
+	"harassment":    true,
+	"hate":          true,
+	"violence":      true,
+	"sexual":        true,
+	"self_harm":     true,
+	"impersonation": true,
+	"other":         true,
+}
+
+var (
+	errAlreadyReported   = errors.New("already reported")
+	errReportNotClaimed  = errors.New("report is not claimed")
+	errNotReportAssignee = errors.New("report is claimed by another moderator")
+)
+
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ReporterID     *uuid.UUID `json:"reporter_id"`
+	ReportedUserID uuid.UUID  `json:"reported_user_id"`
+	ChirpID        *uuid.UUID `json:"chirp_id"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	State          string     `json:"state"`
+	AssigneeID     *uuid.UUID `json:"assignee_id"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+func reportFromDB(r database.Report) Report {
+	report := Report{
+		ID:             r.ID,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+		ReportedUserID: r.ReportedUserID,
+		Reason:         r.Reason,
+		Details:        r.Details,
+		State:          r.State,
+		Resolution:     r.Resolution.String,
+	}
+	if r.ReporterID.Valid {
+		report.ReporterID = &r.ReporterID.UUID
+	}
+	if r.ChirpID.Valid {
+		report.ChirpID = &r.ChirpID.UUID
+	}
+	if r.AssigneeID.Valid {
+		report.AssigneeID = &r.AssigneeID.UUID
+	}
+	if r.ResolvedAt.Valid {
+		report.ResolvedAt = &r.ResolvedAt.Time
+	}
+	return report
+}
+
+type ReportEvent struct {
+	ID        int64      `json:"id"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	FromState string     `json:"from_state,omitempty"`
+	ToState   string     `json:"to_state"`
+	Note      string     `json:"note"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// recordReportEvent appends a state change to a report's history. actorID
+// is uuid.Nil for changes made by the system.
+func recordReportEvent(ctx context.Context, q *database.Queries, reportID, actorID uuid.UUID, from, to, note string) error {
+	return q.CreateReportEvent(ctx, database.CreateReportEventParams{
+		ReportID:  reportID,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		FromState: sql.NullString{String: from, Valid: from != ""},
+		ToState:   to,
+		Note:      note,
+	})
+}
+
+func createReport(ctx context.Context, q *database.Queries, params database.CreateReportParams) (database.Report, error) {
+	report, err := q.CreateReport(ctx, params)
+	if isUniqueViolation(err) {
+		return database.Report{}, errAlreadyReported
+	} else if err != nil {
+		return database.Report{}, err
+	}
+	return report, recordReportEvent(ctx, q, report.ID, params.ReporterID.UUID, "", ReportOpen, "")
+}
+
+type reportParameters struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+func decodeReportParameters(r *http.Request) (reportParameters, error) {
+	params := reportParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return params, err
+	}
+	params.Reason = strings.ToLower(strings.TrimSpace(params.Reason))
+	var reasonErr, detailsErr *FieldError
+	if !reportReasons[params.Reason] {
+		reasonErr = newFieldError("reason", "invalid", errInvalidReport, "reason must be one of spam, harassment, hate, violence, sexual, self_harm, impersonation or other")
+	}
+	if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+		detailsErr = newFieldError("details", "too_long", errInvalidReport,
+			fmt.Sprintf("details are limited to %d characters", maxReportDetailsLength))
+	}
+	return params, validationErrors(reasonErr, detailsErr)
+}
+
+func (cfg *apiConfig) respondWithReport(w http.ResponseWriter, params database.CreateReportParams) {
+	var report database.Report
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		var err error
+		report, err = createReport(context.Background(), q, params)
+		return err
+	})
+	if errors.Is(err, errAlreadyReported) {
+		respondWithError(w, http.StatusConflict, "You have already reported this", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating report", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handleReportChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	params, err := decodeReportParameters(r)
+	var v *ValidationError
+	if errors.As(err, &v) {
+		respondWithValidationError(w, err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	c, err := cfg.db.GetChirpByID(context.Background(), chirpID)
+	if err != nil || c.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if c.UserID == user.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+	cfg.respondWithReport(w, database.CreateReportParams{
+		ReporterID:     uuid.NullUUID{UUID: user.ID, Valid: true},
+		ReportedUserID: c.UserID,
+		ChirpID:        uuid.NullUUID{UUID: c.ID, Valid: true},
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+}
+
+func (cfg *apiConfig) handleReportUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	params, err := decodeReportParameters(r)
+	var v *ValidationError
+	if errors.As(err, &v) {
+		respondWithValidationError(w, err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if userID == user.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), userID); err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	cfg.respondWithReport(w, database.CreateReportParams{
+		ReporterID:     uuid.NullUUID{UUID: user.ID, Valid: true},
+		ReportedUserID: userID,
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+}
+
+// raiseSystemReport puts c in the moderation queue for reason. A chirp that
+// already has a pending report for reason keeps it, with details updated,
+// so editing a flagged chirp doesn't queue it twice.
+func raiseSystemReport(ctx context.Context, q *database.Queries, c database.Chirp, reason, details string) error {
+	report, err := q.CreateSystemReport(ctx, database.CreateSystemReportParams{
+		ReportedUserID: c.UserID,
+		ChirpID:        uuid.NullUUID{UUID: c.ID, Valid: true},
+		Reason:         reason,
+		Details:        details,
+	})
+	if err == sql.ErrNoRows {
+		return q.UpdatePendingSystemReport(ctx, database.UpdatePendingSystemReportParams{
+			Details: details,
+			ChirpID: uuid.NullUUID{UUID: c.ID, Valid: true},
+			Reason:  reason,
+		})
+	} else if err != nil {
+		return err
+	}
+	return recordReportEvent(ctx, q, report.ID, uuid.Nil, "", ReportOpen, "")
+}
+
+// flagChirp puts c in the moderation queue when the word filter flagged
+// its body.
+func flagChirp(ctx context.Context, q *database.Queries, c database.Chirp, result moderation.Result) error {
+	words := result.Words(moderation.ActionFlag)
+	if len(words) == 0 {
+		return nil
+	}
+	return raiseSystemReport(ctx, q, c, ReasonFilteredLanguage, matchedPrefix+strings.Join(words, ", "))
+}
+
+func (cfg *apiConfig) handleListReports(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	states := []string{ReportOpen, ReportClaimed}
+	if s := r.URL.Query().Get("state"); s != "" {
+		states = strings.Split(s, ",")
+	}
+	limit, offset := parsePagination(r)
+	reports, err := cfg.db.ListReportQueue(context.Background(), database.ListReportQueueParams{
+		States: states,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing reports", err)
+		return
+	}
+	result := []Report{}
+	for _, report := range reports {
+		result = append(result, reportFromDB(report))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) getReport(w http.ResponseWriter, r *http.Request) (database.Report, bool) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return database.Report{}, false
+	}
+	report, err := cfg.db.GetReport(context.Background(), reportID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return database.Report{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error looking up report", err)
+		return database.Report{}, false
+	}
+	return report, true
+}
+
+func (cfg *apiConfig) handleGetReport(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	report, ok := cfg.getReport(w, r)
+	if !ok {
+		return
+	}
+	events, err := cfg.db.ListReportEvents(context.Background(), report.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading report history", err)
+		return
+	}
+	history := []ReportEvent{}
+	for _, e := range events {
+		event := ReportEvent{
+			ID:        e.ID,
+			FromState: e.FromState.String,
+			ToState:   e.ToState,
+			Note:      e.Note,
+			CreatedAt: e.CreatedAt,
+		}
+		if e.ActorID.Valid {
+			event.ActorID = &e.ActorID.UUID
+		}
+		history = append(history, event)
+	}
+	type response struct {
+		Report
+		History []ReportEvent `json:"history"`
+	}
+	respondWithJSON(w, http.StatusOK, response{Report: reportFromDB(report), History: history})
+}
+
+func (cfg *apiConfig) handleClaimReport(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	report, ok := cfg.getReport(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		var err error
+		report, err = q.ClaimReport(context.Background(), database.ClaimReportParams{
+			AssigneeID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+			ID:         report.ID,
+		})
+		if err != nil {
+			return err
+		}
+		return recordReportEvent(context.Background(), q, report.ID, moderator.ID, ReportOpen, ReportClaimed, "")
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Report is not open", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error claiming report", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+// canActOnReport reports whether moderator may release or resolve a
+// claimed report: the assignee can, and admins can step in for anyone.
+func canActOnReport(moderator database.User, report database.Report) bool {
+	return report.AssigneeID.UUID == moderator.ID || auth.Role(moderator.Role).Includes(auth.RoleAdmin)
+}
+
+// lockClaimedReport locks report id for the rest of the transaction and
+// checks that it is claimed and that moderator may act on it, so a report
+// can't be released or resolved by someone it was just reassigned from.
+func lockClaimedReport(ctx context.Context, q *database.Queries, moderator database.User, id uuid.UUID) (database.Report, error) {
+	report, err := q.GetReportForUpdate(ctx, id)
+	if err != nil {
+		return database.Report{}, err
+	}
+	if report.State != ReportClaimed {
+		return database.Report{}, errReportNotClaimed
+	}
+	if !canActOnReport(moderator, report) {
+		return database.Report{}, errNotReportAssignee
+	}
+	return report, nil
+}
+
+func (cfg *apiConfig) handleReleaseReport(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	report, ok := cfg.getReport(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		if _, err := lockClaimedReport(context.Background(), q, moderator, report.ID); err != nil {
+			return err
+		}
+		var err error
+		report, err = q.ReleaseReport(context.Background(), report.ID)
+		if err != nil {
+			return err
+		}
+		return recordReportEvent(context.Background(), q, report.ID, moderator.ID, ReportClaimed, ReportOpen, "")
+	})
+	if errors.Is(err, errReportNotClaimed) || err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Report is not claimed", err)
+		return
+	} else if errors.Is(err, errNotReportAssignee) {
+		respondWithError(w, http.StatusForbidden, "Report is claimed by another moderator", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error releasing report", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handleResolveReport(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	report, ok := cfg.getReport(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Action   string `json:"action"`
+		Note     string `json:"note"`
+		Duration string `json:"duration"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if report.State != ReportClaimed {
+		respondWithError(w, http.StatusConflict, "Claim the report before resolving it", nil)
+		return
+	}
+	state := ReportResolved
+	var suspendUntil time.Time
+	switch params.Action {
+	case ResolutionHideChirp:
+		if !report.ChirpID.Valid {
+			respondWithValidationError(w, newFieldError("action", "invalid", errInvalidReport, "this report is not about a chirp"))
+			return
+		}
+	case ResolutionWarn:
+	case ResolutionSuspend:
+		duration, err := parseSuspension(params.Duration)
+		if err != nil {
+			respondWithValidationError(w, newFieldError("duration", "invalid", errInvalidReport, err.Error()))
+			return
+		}
+		target, err := cfg.db.GetUserByID(context.Background(), report.ReportedUserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error looking up reported user", err)
+			return
+		}
+		if !canRestrict(moderator, target) {
+			respondWithError(w, http.StatusForbidden, "only admins can suspend staff", nil)
+			return
+		}
+		suspendUntil = time.Now().UTC().Add(duration)
+	case ResolutionDismiss:
+		state = ReportDismissed
+	default:
+		respondWithValidationError(w, newFieldError("action", "invalid", errInvalidReport, "action must be hide_chirp, warn, suspend or dismiss"))
+		return
+	}
+
+	err := cfg.withTx(context.Background(), func(q *database.Queries) error {
+		ctx := context.Background()
+		if _, err := lockClaimedReport(ctx, q, moderator, report.ID); err != nil {
+			return err
+		}
+		switch params.Action {
+		case ResolutionHideChirp:
+			if _, err := q.HideChirp(ctx, report.ChirpID.UUID); err != nil && err != sql.ErrNoRows {
+				return err
+			}
+		case ResolutionWarn:
+			_, err := q.CreateUserWarning(ctx, database.CreateUserWarningParams{
+				UserID:      report.ReportedUserID,
+				ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+				ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+				Reason:      params.Note,
+			})
+			if err != nil {
+				return err
+			}
+		case ResolutionSuspend:
+			if _, err := setAccountState(ctx, q, report.ReportedUserID, AccountSuspended, suspendUntil); err != nil {
+				return err
+			}
+		case ResolutionDismiss:
+			// A chirp found to be fine comes out of any spam hold.
+			if report.ChirpID.Valid {
+				if _, err := q.RestoreHeldChirp(ctx, report.ChirpID.UUID); err != nil {
+					return err
+				}
+				if err := releaseHeldChirp(ctx, q, report.ChirpID.UUID); err != nil {
+					return err
+				}
+			}
+		}
+		resolution := sql.NullString{String: params.Action, Valid: true}
+		var err error
+		report, err = q.ResolveReport(ctx, database.ResolveReportParams{
+			State:      state,
+			Resolution: resolution,
+			ID:         report.ID,
+		})
+		if err != nil {
+			return err
+		}
+		note := params.Action
+		if params.Note != "" {
+			note += ": " + params.Note
+		}
+		if err := recordReportEvent(ctx, q, report.ID, moderator.ID, ReportClaimed, state, note); err != nil {
+			return err
+		}
+		action, targetType, targetID := "report.dismiss", "report", report.ID
+		switch params.Action {
+		case ResolutionHideChirp:
+			action, targetType, targetID = "chirp.hide", "chirp", report.ChirpID.UUID
+		case ResolutionWarn:
+			action, targetType, targetID = "user.warn", "user", report.ReportedUserID
+		case ResolutionSuspend:
+			action, targetType, targetID = "user.suspend", "user", report.ReportedUserID
+		}
+		if err := recordAdminAction(ctx, q, r, moderator, action, targetType, targetID.String(), params.Note); err != nil {
+			return err
+		}
+		if !report.ChirpID.Valid {
+			return nil
+		}
+		// Other pending reports about the same chirp share the outcome.
+		siblings, err := q.ResolveOpenReportsForChirp(ctx, database.ResolveOpenReportsForChirpParams{
+			State:      state,
+			Resolution: resolution,
+			AssigneeID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+			ChirpID:    report.ChirpID,
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range siblings {
+			if err := recordReportEvent(ctx, q, id, moderator.ID, ReportOpen, state, note+" (via report "+report.ID.String()+")"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errReportNotClaimed) || err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Report is no longer claimed", err)
+		return
+	} else if errors.Is(err, errNotReportAssignee) {
+		respondWithError(w, http.StatusForbidden, "Report is claimed by another moderator", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error resolving report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+type Warning struct {
+	ID        uuid.UUID `json:"id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleListWarnings(w http.ResponseWriter, r *http.Request, user database.User) {
+	warnings, err := cfg.db.ListUserWarnings(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing warnings", err)
+		return
+	}
+	result := []Warning{}
+	for _, warning := range warnings {
+		result = append(result, Warning{ID: warning.ID, Reason: warning.Reason, CreatedAt: warning.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
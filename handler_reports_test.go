@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+	"github.com/lib/pq"
+)
+
+var reportColumns = []string{"id", "created_at", "updated_at", "reporter_id", "reported_user_id", "chirp_id", "reason", "details", "state", "assignee_id", "resolution", "resolved_at"}
+
+// reportRow returns a report about a chirp in state, assigned to assignee
+// unless that is uuid.Nil.
+func reportRow(id uuid.UUID, state string, assignee uuid.UUID) *sqlmock.Rows {
+	now := time.Now()
+	var assigneeID any
+	if assignee != uuid.Nil {
+		assigneeID = assignee
+	}
+	return sqlmock.NewRows(reportColumns).
+		AddRow(id, now, now, uuid.New(), uuid.New(), uuid.New(), "spam", "", state, assigneeID, nil, nil)
+}
+
+func newReportRequest(method, reportID, body string) *http.Request {
+	req := httptest.NewRequest(method, "/admin/reports/"+reportID, strings.NewReader(body))
+	req.SetPathValue("reportID", reportID)
+	return req
+}
+
+func TestFlagChirpUpdatesPendingReport(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	c := database.Chirp{ID: uuid.New(), UserID: uuid.New()}
+	f, _ := moderation.NewFilter([]moderation.Rule{{Word: "gosh", Action: moderation.ActionFlag}})
+
+	mock.ExpectQuery("INSERT INTO reports .*ON CONFLICT").
+		WithArgs(c.UserID, c.ID, ReasonFilteredLanguage, "matched: gosh").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE reports\\s+SET details").
+		WithArgs("matched: gosh", c.ID, ReasonFilteredLanguage).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := flagChirp(context.Background(), cfg.db, c, f.Check("gosh, edited")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReportChirpTwiceConflicts(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := database.User{ID: uuid.New()}
+	chirpID := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM chirps").WithArgs(chirpID).
+		WillReturnRows(chirpRows(uuid.New(), chirpID))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO reports").WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpID.String()+"/report", strings.NewReader(`{"reason":"spam"}`))
+	req.SetPathValue("chirpID", chirpID.String())
+	rec := httptest.NewRecorder()
+	cfg.handleReportChirp(rec, req, user)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestReleaseReportChecksAssigneeUnderLock(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	moderator := database.User{ID: uuid.New(), Role: "moderator"}
+	reportID := uuid.New()
+	// The report was ours when loaded but another moderator holds it by
+	// the time the row is locked.
+	mock.ExpectQuery("SELECT .* FROM reports").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportClaimed, moderator.ID))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM reports\\s+WHERE id = \\$1\\s+FOR UPDATE").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportClaimed, uuid.New()))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	cfg.handleReleaseReport(rec, newReportRequest(http.MethodPost, reportID.String(), ""), moderator)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestResolveReportReleasedMeanwhile(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	moderator := database.User{ID: uuid.New(), Role: "moderator"}
+	reportID := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM reports").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportClaimed, moderator.ID))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportOpen, uuid.Nil))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	cfg.handleResolveReport(rec, newReportRequest(http.MethodPost, reportID.String(), `{"action":"dismiss"}`), moderator)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestListChirpFlags(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	now := time.Now()
+	reportID, chirpID := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM reports\\s+WHERE reason = \\$1 AND reporter_id IS NULL").
+		WithArgs(ReasonFilteredLanguage, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(reportColumns).
+			AddRow(reportID, now, now, nil, uuid.New(), chirpID, ReasonFilteredLanguage, "matched: gosh, heck", ReportOpen, nil, nil, nil))
+
+	rec := httptest.NewRecorder()
+	cfg.handleListChirpFlags(rec, httptest.NewRequest(http.MethodGet, "/admin/moderation/flags", nil), database.User{})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var flags []ChirpFlag
+	if err := json.Unmarshal(rec.Body.Bytes(), &flags); err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 || flags[0].ChirpID != chirpID || strings.Join(flags[0].MatchedWords, "|") != "gosh|heck" {
+		t.Errorf("flags = %+v, want chirp %s matching gosh and heck", flags, chirpID)
+	}
+}
+
+func TestDismissingReportRestoresHeldChirp(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	moderator := database.User{ID: uuid.New(), Role: "moderator"}
+	reportID := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM reports").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportClaimed, moderator.ID))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(reportID).
+		WillReturnRows(reportRow(reportID, ReportClaimed, moderator.ID))
+	mock.ExpectExec("UPDATE chirps\\s+SET hidden_at = NULL, shadowed_at = NULL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Held after it was announced, by an edit, so it isn't announced again.
+	mock.ExpectQuery("DELETE FROM held_chirps").
+		WillReturnRows(sqlmock.NewRows([]string{"announced"}).AddRow(true))
+	mock.ExpectQuery("UPDATE reports\\s+SET state = \\$1").
+		WithArgs(ReportDismissed, ResolutionDismiss, reportID).
+		WillReturnRows(reportRow(reportID, ReportDismissed, moderator.ID))
+	mock.ExpectExec("INSERT INTO report_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO admin_actions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports\\s+SET state = \\$1, resolution = \\$2, assignee_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	cfg.handleResolveReport(rec, newReportRequest(http.MethodPost, reportID.String(), `{"action":"dismiss"}`), moderator)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
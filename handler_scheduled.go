@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/leader"
+)
+
+const (
+	ScheduledPending   = "pending"
+	ScheduledPublished = "published"
+	ScheduledCanceled  = "canceled"
+	ScheduledFailed    = "failed"
+
+	maxScheduleAhead       = 365 * 24 * time.Hour
+	scheduledPollInterval  = 15 * time.Second
+	scheduledPublishBatch  = 100
+	scheduledPublisherName = "scheduled-chirps"
+)
+
+var (
+	errInvalidSchedule = errors.New("invalid schedule")
+	// errScheduleChanged rolls back a publish when the scheduled chirp was
+	// canceled or rescheduled while it was being posted.
+	errScheduleChanged = errors.New("scheduled chirp changed while publishing")
+)
+
+type ScheduledChirp struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Body      string       `json:"body"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id"`
+	Media     []chirpMedia `json:"media"`
+	PublishAt time.Time    `json:"publish_at"`
+	State     string       `json:"state"`
+	ChirpID   *uuid.UUID   `json:"chirp_id"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+func scheduledChirpFromDB(s database.ScheduledChirp) ScheduledChirp {
+	chirp := ScheduledChirp{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Body:      s.Body,
+		PublishAt: s.PublishAt,
+		State:     s.State,
+		LastError: s.LastError.String,
+	}
+	chirp.Media, _ = decodeChirpMedia(s.Media)
+	if s.ReplyToID.Valid {
+		chirp.ReplyToID = &s.ReplyToID.UUID
+	}
+	if s.ChirpID.Valid {
+		chirp.ChirpID = &s.ChirpID.UUID
+	}
+	return chirp
+}
+
+// checkPublishAt makes sure a publish time is in the future but not too far.
+func checkPublishAt(publishAt time.Time) *FieldError {
+	now := time.Now()
+	if !publishAt.After(now) {
+		return newFieldError("publish_at", "in_past", errInvalidSchedule, "publish_at must be in the future")
+	}
+	if publishAt.Sub(now) > maxScheduleAhead {
+		return newFieldError("publish_at", "too_far", errInvalidSchedule,
+			fmt.Sprintf("chirps can be scheduled at most %d days ahead", int(maxScheduleAhead.Hours()/24)))
+	}
+	return nil
+}
+
+// scheduleChirp stores in to be posted at publishAt. The body and media are
+// checked now so mistakes surface straight away; everything is checked
+// again when the chirp is published.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, author database.User, in newChirp, publishAt time.Time) {
+	ent := cfg.entitlementsFor(author.ID)
+	if !ent.CanScheduleChirps {
+		respondWithError(w, http.StatusForbidden, "scheduling chirps requires Chirpy Red", nil)
+		return
+	}
+	if err := checkAccountActive(author); err != nil {
+		respondWithAccountError(w, author, err)
+		return
+	}
+	_, bodyErr := validate_chirp(in.Body, ent.MaxChirpLength, cfg.moderationFilter())
+	if err := validationErrors(bodyErr, cfg.validateChirpMedia(in.Media), checkPublishAt(publishAt)); err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	media, err := encodeChirpMedia(in.Media)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error scheduling chirp", err)
+		return
+	}
+	params := database.CreateScheduledChirpParams{
+		UserID:    author.ID,
+		Body:      in.Body,
+		Media:     media,
+		PublishAt: publishAt,
+	}
+	if in.ReplyToID != nil {
+		params.ReplyToID = uuid.NullUUID{UUID: *in.ReplyToID, Valid: true}
+	}
+	scheduled, err := cfg.db.CreateScheduledChirp(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error scheduling chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, scheduledChirpFromDB(scheduled))
+}
+
+func (cfg *apiConfig) handleListScheduledChirps(w http.ResponseWriter, r *http.Request, user database.User) {
+	states := []string{ScheduledPending, ScheduledFailed}
+	if s := r.URL.Query().Get("state"); s != "" {
+		states = strings.Split(s, ",")
+	}
+	limit, offset := parsePagination(r)
+	scheduled, err := cfg.db.ListScheduledChirps(context.Background(), database.ListScheduledChirpsParams{
+		UserID: user.ID,
+		States: states,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing scheduled chirps", err)
+		return
+	}
+	result := []ScheduledChirp{}
+	for _, s := range scheduled {
+		result = append(result, scheduledChirpFromDB(s))
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// getOwnScheduledChirp loads the {scheduledID} of the request, treating
+// other users' scheduled chirps as missing.
+func (cfg *apiConfig) getOwnScheduledChirp(w http.ResponseWriter, r *http.Request, user database.User) (database.ScheduledChirp, bool) {
+	id, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return database.ScheduledChirp{}, false
+	}
+	scheduled, err := cfg.db.GetScheduledChirp(context.Background(), id)
+	if err == sql.ErrNoRows || (err == nil && scheduled.UserID != user.ID) {
+		respondWithError(w, http.StatusNotFound, "Scheduled chirp not found", err)
+		return database.ScheduledChirp{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error looking up scheduled chirp", err)
+		return database.ScheduledChirp{}, false
+	}
+	return scheduled, true
+}
+
+func (cfg *apiConfig) handleCancelScheduledChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	scheduled, ok := cfg.getOwnScheduledChirp(w, r, user)
+	if !ok {
+		return
+	}
+	n, err := cfg.db.CancelScheduledChirp(context.Background(), scheduled.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error canceling scheduled chirp", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, "Scheduled chirp is no longer pending", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleRescheduleChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	scheduled, ok := cfg.getOwnScheduledChirp(w, r, user)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		PublishAt time.Time `json:"publish_at"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !cfg.entitlementsFor(user.ID).CanScheduleChirps {
+		respondWithError(w, http.StatusForbidden, "scheduling chirps requires Chirpy Red", nil)
+		return
+	}
+	if err := checkPublishAt(params.PublishAt); err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	updated, err := cfg.db.RescheduleChirp(context.Background(), database.RescheduleChirpParams{
+		PublishAt: params.PublishAt,
+		ID:        scheduled.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Scheduled chirp was already published or canceled", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error rescheduling chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, scheduledChirpFromDB(updated))
+}
+
+// runScheduledPublisher posts scheduled chirps once they're due. Every
+// instance runs it; only the one holding the advisory lock does any work,
+// so nothing is published twice.
+func (cfg *apiConfig) runScheduledPublisher(ctx context.Context) {
+	elector := leader.NewElector(cfg.sqlDB, leader.Key(scheduledPublisherName))
+	defer elector.Release(context.Background())
+	ticker := time.NewTicker(scheduledPollInterval)
+	defer ticker.Stop()
+	for {
+		cfg.publishDueChirpsIfLeader(ctx, elector)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirpsIfLeader runs one round of the publisher, doing nothing
+// unless elector holds the lock.
+func (cfg *apiConfig) publishDueChirpsIfLeader(ctx context.Context, elector *leader.Elector) {
+	if isLeader, err := elector.IsLeader(ctx); err != nil {
+		log.Printf("scheduled chirps: leader election: %s", err)
+	} else if isLeader {
+		cfg.publishDueChirps(ctx)
+	}
+}
+
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) {
+	due, err := cfg.db.ListDueScheduledChirps(ctx, scheduledPublishBatch)
+	if err != nil {
+		log.Printf("scheduled chirps: listing due: %s", err)
+		return
+	}
+	for _, s := range due {
+		if err := cfg.publishScheduledChirp(ctx, s); err != nil {
+			log.Printf("scheduled chirps: publishing %s: %s", s.ID, err)
+		}
+	}
+}
+
+// publishScheduledChirp posts s through the same path as the API. A chirp
+// that can't be posted as it stands, say because its author is suspended
+// or its body now trips the word filter, is marked failed with the reason;
+// other errors leave it pending for the next round.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, s database.ScheduledChirp) error {
+	author, err := cfg.db.GetUserByID(ctx, s.UserID)
+	if err != nil {
+		return err
+	}
+	media, err := decodeChirpMedia(s.Media)
+	if err != nil {
+		return err
+	}
+	in := newChirp{Body: s.Body, Media: media}
+	if s.ReplyToID.Valid {
+		in.ReplyToID = &s.ReplyToID.UUID
+	}
+	_, _, err = cfg.postChirp(ctx, author, in, func(q *database.Queries, c database.Chirp) error {
+		n, err := q.MarkScheduledChirpPublished(ctx, database.MarkScheduledChirpPublishedParams{
+			ChirpID: c.ID,
+			ID:      s.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errScheduleChanged
+		}
+		return nil
+	})
+	if err == nil || errors.Is(err, errScheduleChanged) {
+		return nil
+	}
+	if chirpErrorStatus(err) >= http.StatusInternalServerError {
+		return err
+	}
+	return cfg.db.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+		LastError: sql.NullString{String: err.Error(), Valid: true},
+		ID:        s.ID,
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/leader"
+	"github.com/jcfullmer/chirpy/internal/spam"
+)
+
+var scheduledChirpColumns = []string{"id", "created_at", "updated_at", "user_id", "body", "reply_to_id", "media", "publish_at", "state", "chirp_id", "last_error"}
+
+func scheduledChirpRow(id, user uuid.UUID, publishAt time.Time, state string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(scheduledChirpColumns).
+		AddRow(id, now, now, user, "hello", nil, []byte(`[]`), publishAt, state, nil, nil)
+}
+
+func expectPlan(mock sqlmock.Sqlmock, plan string) {
+	mock.ExpectQuery("SELECT plan FROM subscriptions").
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow(plan))
+}
+
+// sameInstant matches a time at the same instant as t, in any zone.
+type sameInstant struct{ t time.Time }
+
+func (m sameInstant) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	return ok && got.Equal(m.t)
+}
+
+func TestScheduleChirp(t *testing.T) {
+	t.Run("keeps the publish instant", func(t *testing.T) {
+		cfg, mock := newTestConfig(t)
+		author := database.User{ID: uuid.New(), AccountState: AccountActive}
+		publishAt := time.Now().Add(time.Hour).In(time.FixedZone("UTC+5", 5*60*60)).Truncate(time.Second)
+		expectPlan(mock, "red")
+		mock.ExpectQuery("INSERT INTO scheduled_chirps").
+			WithArgs(author.ID, "hello", nil, sqlmock.AnyArg(), sameInstant{publishAt}).
+			WillReturnRows(scheduledChirpRow(uuid.New(), author.ID, publishAt, ScheduledPending))
+
+		rec := httptest.NewRecorder()
+		cfg.scheduleChirp(rec, author, newChirp{Body: "hello"}, publishAt)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+		}
+	})
+
+	t.Run("rejects times in the past", func(t *testing.T) {
+		cfg, mock := newTestConfig(t)
+		author := database.User{ID: uuid.New(), AccountState: AccountActive}
+		expectPlan(mock, "red")
+
+		rec := httptest.NewRecorder()
+		cfg.scheduleChirp(rec, author, newChirp{Body: "hello"}, time.Now().Add(-time.Minute))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestCancelScheduledChirp(t *testing.T) {
+	user := uuid.New()
+	for _, tc := range []struct {
+		name     string
+		owner    uuid.UUID
+		canceled int64
+		want     int
+	}{
+		{"Pending", user, 1, http.StatusNoContent},
+		{"Already Published", user, 0, http.StatusConflict},
+		{"Someone Else's", uuid.New(), -1, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			id := uuid.New()
+			mock.ExpectQuery("SELECT .* FROM scheduled_chirps").WithArgs(id).
+				WillReturnRows(scheduledChirpRow(id, tc.owner, time.Now().Add(time.Hour), ScheduledPending))
+			if tc.canceled >= 0 {
+				mock.ExpectExec("UPDATE scheduled_chirps\\s+SET state = 'canceled'").WithArgs(id).
+					WillReturnResult(sqlmock.NewResult(0, tc.canceled))
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/scheduled_chirps/"+id.String(), nil)
+			req.SetPathValue("scheduledID", id.String())
+			rec := httptest.NewRecorder()
+			cfg.handleCancelScheduledChirp(rec, req, database.User{ID: user})
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestScheduledPublisherOnlyRunsOnLeader(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	key := leader.Key(scheduledPublisherName)
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectQuery("FROM scheduled_chirps\\s+WHERE state = 'pending' AND publish_at <= NOW\\(\\)").
+		WillReturnRows(sqlmock.NewRows(scheduledChirpColumns))
+	// The other instance finds the lock taken and lists nothing.
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(false))
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	first, second := leader.NewElector(cfg.sqlDB, key), leader.NewElector(cfg.sqlDB, key)
+	cfg.publishDueChirpsIfLeader(ctx, first)
+	cfg.publishDueChirpsIfLeader(ctx, second)
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishScheduledChirpRescheduledMeanwhile(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.spam = spam.DefaultConfig()
+	author, id, chirpID := uuid.New(), uuid.New(), uuid.New()
+	s := database.ScheduledChirp{ID: id, UserID: author, Body: "hello", Media: []byte(`[]`), State: ScheduledPending}
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(author).WillReturnRows(userRow(author))
+	expectPlan(mock, "red")
+	mock.ExpectQuery("SELECT NOW\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(time.Now()))
+	mock.ExpectQuery("SELECT id, body, created_at FROM chirps").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "body", "created_at"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO chirps").WillReturnRows(chirpRows(author, chirpID))
+	// Another round, or the author, got to it first: the chirp is rolled
+	// back and the schedule isn't marked failed.
+	mock.ExpectExec("UPDATE scheduled_chirps\\s+SET state = 'published'").
+		WithArgs(chirpID, id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := cfg.publishScheduledChirp(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+}
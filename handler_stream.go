@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+const (
+	streamBuffer      = 64
+	streamReplayBatch = 500
+	streamHeartbeat   = 15 * time.Second
+)
+
+// optionalUser resolves the caller from a bearer token, falling back to an
+// access_token query parameter since browsers' EventSource can't set
+// headers. It returns ok=false when no token was supplied at all.
+func (cfg *apiConfig) optionalUser(r *http.Request) (user database.User, ok bool, err error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return database.User{}, false, nil
+	}
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		return database.User{}, true, err
+	}
+	user, err = cfg.db.GetUserByID(context.Background(), userID)
+	return user, true, err
+}
+
+// streamFilter builds the subscriber filter from ?author_id= (repeatable or
+// comma separated) and ?following=true, which restricts the stream to
+// authors the caller follows. Both filters together match either set. Only
+// public chirp events ever pass, and an authenticated caller never sees
+// authors on either side of a block or that they muted. The returned set
+// must be reloaded when the filter passes an event it is changedBy.
+func (cfg *apiConfig) streamFilter(r *http.Request) (stream.Filter, *hiddenAuthorSet, int, error) {
+	authors := map[uuid.UUID]bool{}
+	for _, v := range r.URL.Query()["author_id"] {
+		for _, s := range strings.Split(v, ",") {
+			id, err := uuid.Parse(strings.TrimSpace(s))
+			if err != nil {
+				return nil, nil, http.StatusBadRequest, fmt.Errorf("invalid author_id %q", s)
+			}
+			authors[id] = true
+		}
+	}
+	user, authed, err := cfg.optionalUser(r)
+	if authed && err != nil {
+		return nil, nil, http.StatusUnauthorized, err
+	}
+	hidden, err := cfg.loadHiddenAuthorSet(context.Background(), user.ID)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	if r.URL.Query().Get("following") == "true" {
+		if !authed {
+			return nil, nil, http.StatusUnauthorized, fmt.Errorf("following filter requires authentication")
+		}
+		followees, err := cfg.db.ListFolloweeIDs(context.Background(), user.ID)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		if len(followees) == 0 && len(authors) == 0 {
+			return func(stream.Event) bool { return false }, hidden, 0, nil
+		}
+		for _, id := range followees {
+			authors[id] = true
+		}
+	}
+	return func(e stream.Event) bool {
+		if hidden.changedBy(e) {
+			return true
+		}
+		return stream.IsChirpEvent(e) && !hidden.has(e.AuthorID) && (len(authors) == 0 || authors[e.AuthorID])
+	}, hidden, 0, nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.Cursor, e.Type, e.Data)
+	return err
+}
+
+func (cfg *apiConfig) handleChirpStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming unsupported", nil)
+		return
+	}
+	filter, hidden, code, err := cfg.streamFilter(r)
+	if err != nil {
+		respondWithError(w, code, err.Error(), err)
+		return
+	}
+	var last stream.Cursor
+	resume := false
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		if last, err = stream.ParseCursor(s); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+		resume = true
+	}
+
+	// Subscribe before replaying so nothing published during the replay is
+	// lost; live events already covered by the replay are skipped below.
+	sub := cfg.streamHub.Subscribe(filter, streamBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	if resume {
+		for {
+			rows, err := cfg.db.ListCommittedOutboxEventsAfter(r.Context(), database.ListCommittedOutboxEventsAfterParams{
+				AfterTxid:  last.Txid,
+				AfterID:    last.ID,
+				EventTypes: stream.ChirpEvents,
+				Limit:      streamReplayBatch,
+			})
+			if err != nil {
+				log.Printf("error replaying chirp stream after %s: %s", last, err)
+				return
+			}
+			for _, row := range rows {
+				last = stream.Cursor{Txid: row.Txid, ID: row.ID}
+				e, ok := stream.EventFromOutbox(row)
+				if !ok || !filter(e) {
+					continue
+				}
+				if writeStreamEvent(w, e) != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if len(rows) < streamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID and replays what it missed.
+				return
+			}
+			if hidden.changedBy(e) {
+				if err := cfg.reloadHiddenAuthorSet(r.Context(), hidden); err != nil {
+					log.Printf("error reloading hidden authors for chirp stream: %s", err)
+					return
+				}
+				continue
+			}
+			// Events queued before a block took effect are checked again.
+			if !e.Cursor.After(last) || hidden.has(e.AuthorID) {
+				continue
+			}
+			if writeStreamEvent(w, e) != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/entities"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+	maxChirpLinkPreviews  = 4
+)
+
+type TrendingTag struct {
+	Tag  string `json:"tag"`
+	Uses int64  `json:"uses"`
+}
+
+// storeChirpEntities replaces the stored hashtags, mentions and links of
+// chirpID with those parsed from body and returns the users that were
+// mentioned. Handles that don't belong to anyone, or belong to someone on
+// either side of a block with authorID, are not stored. Links are
+// queued for the unfurl worker, up to maxChirpLinkPreviews per chirp.
+func storeChirpEntities(ctx context.Context, q *database.Queries, authorID, chirpID uuid.UUID, body string) ([]database.User, error) {
+	if err := q.DeleteChirpHashtags(ctx, chirpID); err != nil {
+		return nil, err
+	}
+	if err := q.DeleteChirpMentions(ctx, chirpID); err != nil {
+		return nil, err
+	}
+	if err := q.DeleteChirpLinks(ctx, chirpID); err != nil {
+		return nil, err
+	}
+	ents := entities.Parse(body)
+	for i, link := range ents.Links() {
+		if i == maxChirpLinkPreviews {
+			break
+		}
+		if err := q.EnqueueLinkPreview(ctx, link); err != nil {
+			return nil, err
+		}
+		err := q.AddChirpLink(ctx, database.AddChirpLinkParams{
+			ChirpID:  chirpID,
+			Url:      link,
+			Position: int32(i),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range ents.Hashtags {
+		hashtagID, err := q.UpsertHashtag(ctx, h.Tag)
+		if err != nil {
+			return nil, err
+		}
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:     chirpID,
+			HashtagID:   hashtagID,
+			StartOffset: int32(h.Start),
+			EndOffset:   int32(h.End),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	handles := ents.Handles()
+	if len(handles) == 0 {
+		return nil, nil
+	}
+	users, err := q.GetMentionableUsersByHandles(ctx, database.GetMentionableUsersByHandlesParams{
+		Handles:  handles,
+		AuthorID: authorID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	byHandle := map[string]uuid.UUID{}
+	for _, u := range users {
+		byHandle[u.Handle.String] = u.ID
+	}
+	for _, m := range ents.Mentions {
+		userID, ok := byHandle[m.Handle]
+		if !ok {
+			continue
+		}
+		err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID:     chirpID,
+			UserID:      userID,
+			StartOffset: int32(m.Start),
+			EndOffset:   int32(m.End),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (cfg *apiConfig) handleGetTag(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	limit, offset := parsePagination(r)
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return
+	}
+	chirpsDB, err := cfg.db.ListChirpsByTag(context.Background(), database.ListChirpsByTagParams{
+		Tag:      tag,
+		ViewerID: viewerID,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps for tag", err)
+		return
+	}
+	result, err := hydrateChirps(context.Background(), cfg.db, chirpsDB, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps for tag", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handleTrendingTags ranks tags by how many visible chirps used them within
+// ?window= (a Go duration, default 24h, at most a week) ending now.
+func (cfg *apiConfig) handleTrendingTags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid window", err)
+			return
+		}
+		window = min(d, maxTrendingWindow)
+	}
+	limit, _ := parsePagination(r)
+	if r.URL.Query().Get("limit") == "" {
+		limit = defaultTrendingLimit
+	}
+	rows, err := cfg.db.TrendingHashtags(context.Background(), database.TrendingHashtagsParams{
+		WindowSeconds: window.Seconds(),
+		Limit:         limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing trending tags", err)
+		return
+	}
+	result := []TrendingTag{}
+	for _, row := range rows {
+		result = append(result, TrendingTag{Tag: row.Tag, Uses: row.Uses})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
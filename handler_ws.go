@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+const (
+	wsSendBuffer    = 64
+	wsReplyBuffer   = 16
+	wsMaxTopics     = 50
+	wsPingInterval  = 30 * time.Second
+	wsPongWait      = 45 * time.Second
+	wsWriteWait     = 10 * time.Second
+	wsMaxClientSize = 4 << 10
+)
+
+// Topics a WebSocket client can subscribe to:
+//
+//	timeline:<user_id>  chirps created or deleted by that user
+//	chirp:<chirp_id>    events about a single chirp and replies to it
+//	notifications       the caller's own notifications
+const (
+	wsTopicTimeline      = "timeline:"
+	wsTopicChirp         = "chirp:"
+	wsTopicNotifications = "notifications"
+)
+
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+type wsServerMessage struct {
+	Type   string          `json:"type"`
+	Topic  string          `json:"topic,omitempty"`
+	Topics []string        `json:"topics,omitempty"`
+	Event  string          `json:"event,omitempty"`
+	ID     int64           `json:"id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// wsSession holds one connection's topic subscriptions. The hub calls
+// matches from Publish, so it must be cheap and safe for concurrent use.
+type wsSession struct {
+	user database.User
+	// hidden holds the authors the user blocked or muted, or who blocked
+	// them; their chirp events are never delivered.
+	hidden *hiddenAuthorSet
+	mu     sync.RWMutex
+	topics map[string]bool
+}
+
+func validateWSTopic(topic string) error {
+	switch {
+	case topic == wsTopicNotifications:
+		return nil
+	case strings.HasPrefix(topic, wsTopicTimeline):
+		_, err := uuid.Parse(strings.TrimPrefix(topic, wsTopicTimeline))
+		return err
+	case strings.HasPrefix(topic, wsTopicChirp):
+		_, err := uuid.Parse(strings.TrimPrefix(topic, wsTopicChirp))
+		return err
+	}
+	return fmt.Errorf("unknown topic %q", topic)
+}
+
+func (s *wsSession) topicsFor(e stream.Event) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []string
+	if e.RecipientID != uuid.Nil {
+		if e.Type == stream.EventNotificationCreated && e.RecipientID == s.user.ID && s.topics[wsTopicNotifications] {
+			matched = append(matched, wsTopicNotifications)
+		}
+		return matched
+	}
+	if s.hidden.has(e.AuthorID) {
+		return nil
+	}
+	if t := wsTopicTimeline + e.AuthorID.String(); s.topics[t] {
+		matched = append(matched, t)
+	}
+	if t := wsTopicChirp + e.ChirpID.String(); s.topics[t] {
+		matched = append(matched, t)
+	}
+	if e.ParentID != uuid.Nil {
+		if t := wsTopicChirp + e.ParentID.String(); s.topics[t] {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+func (s *wsSession) matches(e stream.Event) bool {
+	return s.hidden.changedBy(e) || len(s.topicsFor(e)) > 0
+}
+
+func (s *wsSession) handle(msg wsClientMessage) wsServerMessage {
+	switch msg.Type {
+	case "subscribe":
+		if err := validateWSTopic(msg.Topic); err != nil {
+			return wsServerMessage{Type: "error", Topic: msg.Topic, Error: err.Error()}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.topics[msg.Topic] && len(s.topics) >= wsMaxTopics {
+			return wsServerMessage{Type: "error", Topic: msg.Topic, Error: "too many subscriptions"}
+		}
+		s.topics[msg.Topic] = true
+		return wsServerMessage{Type: "subscribed", Topic: msg.Topic}
+	case "unsubscribe":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.topics, msg.Topic)
+		return wsServerMessage{Type: "unsubscribed", Topic: msg.Topic}
+	case "ping":
+		return wsServerMessage{Type: "pong"}
+	}
+	return wsServerMessage{Type: "error", Error: fmt.Sprintf("unknown message type %q", msg.Type)}
+}
+
+func (cfg *apiConfig) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	user, ok, err := cfg.optionalUser(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "missing bearer token", nil)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return
+	}
+	hidden, err := cfg.loadHiddenAuthorSet(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading blocked users", err)
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(wsMaxClientSize)
+
+	session := &wsSession{user: user, hidden: hidden, topics: map[string]bool{}}
+	// The subscription's buffer is the connection's send buffer; when a
+	// client can't keep up the hub drops it and we close the socket.
+	sub := cfg.streamHub.Subscribe(session.matches, wsSendBuffer)
+	replies := make(chan wsServerMessage, wsReplyBuffer)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer func() {
+		cancel()
+		sub.Close()
+		conn.Close(websocket.StatusGoingAway, "")
+	}()
+	go cfg.wsWriteLoop(ctx, conn, session, sub, replies)
+	go wsKeepalive(ctx, conn)
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		msg := wsClientMessage{}
+		reply := wsServerMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			reply = wsServerMessage{Type: "error", Error: "invalid message"}
+		} else {
+			reply = session.handle(msg)
+		}
+		select {
+		case replies <- reply:
+		default:
+			conn.Close(websocket.StatusTryAgainLater, "slow consumer")
+			return
+		}
+	}
+}
+
+// wsWriteLoop owns outbound messages for a connection: events and replies
+// to client messages.
+func (cfg *apiConfig) wsWriteLoop(ctx context.Context, conn *websocket.Conn, session *wsSession, sub *stream.Subscription, replies <-chan wsServerMessage) {
+	write := func(msg wsServerMessage) bool {
+		dat, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("error encoding websocket message: %s", err)
+			return true
+		}
+		writeCtx, cancel := context.WithTimeout(ctx, wsWriteWait)
+		defer cancel()
+		return conn.Write(writeCtx, websocket.MessageText, dat) == nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "slow consumer")
+				return
+			}
+			if session.hidden.changedBy(e) {
+				if err := cfg.reloadHiddenAuthorSet(ctx, session.hidden); err != nil {
+					log.Printf("error reloading hidden authors for websocket: %s", err)
+					conn.Close(websocket.StatusInternalError, "")
+					return
+				}
+				continue
+			}
+			topics := session.topicsFor(e)
+			if len(topics) == 0 {
+				// Unsubscribed, or the author hidden, after the hub queued
+				// the event.
+				continue
+			}
+			if !write(wsServerMessage{Type: "event", Topics: topics, Event: e.Type, ID: e.ID, Data: e.Data}) {
+				return
+			}
+		case reply := <-replies:
+			if !write(reply) {
+				return
+			}
+		}
+	}
+}
+
+// wsKeepalive pings the client every wsPingInterval and disconnects it if
+// a pong doesn't come back within wsPongWait.
+func wsKeepalive(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, wsPongWait)
+		err := conn.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			conn.Close(websocket.StatusPolicyViolation, "ping timeout")
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+func TestWSSessionRoutesRepliesToParentTopic(t *testing.T) {
+	parent, reply := uuid.New(), uuid.New()
+	session := &wsSession{hidden: &hiddenAuthorSet{}, topics: map[string]bool{wsTopicChirp + parent.String(): true}}
+
+	topics := session.topicsFor(stream.Event{Type: stream.EventChirpCreated, AuthorID: uuid.New(), ChirpID: reply, ParentID: parent})
+	if len(topics) != 1 || topics[0] != wsTopicChirp+parent.String() {
+		t.Errorf("reply matched %v, want the parent's topic", topics)
+	}
+	if topics := session.topicsFor(stream.Event{Type: stream.EventChirpCreated, AuthorID: uuid.New(), ChirpID: reply}); len(topics) != 0 {
+		t.Errorf("unrelated chirp matched %v", topics)
+	}
+}
+
+// dialTestWebSocket connects user to cfg's WebSocket endpoint and
+// subscribes to topic. It returns a function reading the next message.
+func dialTestWebSocket(t *testing.T, cfg *apiConfig, user uuid.UUID, topic string) func() wsServerMessage {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(cfg.handleWebSocket))
+	t.Cleanup(srv.Close)
+	token, err := auth.MakeJWT(user, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer " + token}},
+	})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+
+	read := func() wsServerMessage {
+		t.Helper()
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		msg := wsServerMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("bad message %s: %v", data, err)
+		}
+		return msg
+	}
+	sub, _ := json.Marshal(wsClientMessage{Type: "subscribe", Topic: topic})
+	if err := conn.Write(ctx, websocket.MessageText, sub); err != nil {
+		t.Fatal(err)
+	}
+	if msg := read(); msg.Type != "subscribed" {
+		t.Fatalf("got %+v, want subscribed", msg)
+	}
+	return read
+}
+
+func TestWebSocketDeliversSubscribedEvents(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.JWTSecret = "secret"
+	cfg.streamHub = stream.NewHub()
+	user := uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).WillReturnRows(userRow(user))
+	mock.ExpectQuery("SELECT blocked_id AS user_id").WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	parent := uuid.New()
+	read := dialTestWebSocket(t, cfg, user, wsTopicChirp+parent.String())
+
+	cfg.streamHub.Publish(stream.Event{ID: 3, Type: stream.EventChirpCreated, AuthorID: uuid.New(), ChirpID: uuid.New(), ParentID: parent, Data: json.RawMessage(`{}`)})
+	if msg := read(); msg.Type != "event" || msg.ID != 3 || len(msg.Topics) != 1 {
+		t.Errorf("got %+v, want event 3 on the parent's topic", msg)
+	}
+}
+
+func TestWebSocketHidesAuthorsBlockedAfterConnecting(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.JWTSecret = "secret"
+	cfg.streamHub = stream.NewHub()
+	user, blocked := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).WillReturnRows(userRow(user))
+	mock.ExpectQuery("SELECT blocked_id AS user_id").WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery("SELECT blocked_id AS user_id").WithArgs(user).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(blocked))
+
+	parent := uuid.New()
+	read := dialTestWebSocket(t, cfg, user, wsTopicChirp+parent.String())
+
+	// The hub's filter has already passed the blocked author's reply by the
+	// time the session reloads, so delivery has to check again.
+	cfg.streamHub.Publish(stream.Event{ID: 3, Type: stream.EventHiddenAuthorsChanged, RecipientID: user})
+	cfg.streamHub.Publish(stream.Event{ID: 4, Type: stream.EventChirpCreated, AuthorID: blocked, ChirpID: uuid.New(), ParentID: parent, Data: json.RawMessage(`{}`)})
+	cfg.streamHub.Publish(stream.Event{ID: 5, Type: stream.EventChirpCreated, AuthorID: uuid.New(), ChirpID: uuid.New(), ParentID: parent, Data: json.RawMessage(`{}`)})
+	if msg := read(); msg.Type != "event" || msg.ID != 5 {
+		t.Errorf("got %+v, want event 5", msg)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/entitlements"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+)
+
+// newTestConfig returns an apiConfig backed by a mock database. Expected
+// queries are matched as regular expressions against the generated SQL,
+// and any expectation left unmet fails the test.
+func newTestConfig(t *testing.T) (*apiConfig, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	cfg := &apiConfig{
+		db:           database.New(db),
+		sqlDB:        db,
+		entitlements: entitlements.DefaultCatalog(),
+	}
+	filter, err := moderation.NewFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.moderation.Store(filter)
+	return cfg, mock
+}
+
+var userColumns = []string{"id", "created_at", "updated_at", "email", "hashed_password", "role", "handle", "suspended_until", "account_state"}
+
+// userRow returns a single active user row with the given ID.
+func userRow(id uuid.UUID) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(userColumns).
+		AddRow(id, now, now, "user@example.com", "", "user", nil, nil, "active")
+}
+
+var chirpColumns = []string{"id", "created_at", "updated_at", "body", "user_id", "hidden_at", "reply_to_id", "shadowed_at"}
+
+// chirpRows returns chirp rows by author with the given IDs, all visible.
+func chirpRows(author uuid.UUID, ids ...uuid.UUID) *sqlmock.Rows {
+	rows := sqlmock.NewRows(chirpColumns)
+	for _, id := range ids {
+		now := time.Now()
+		rows.AddRow(id, now, now, "hello", author, nil, nil, nil)
+	}
+	return rows
+}
@@ -0,0 +1,89 @@
+// Package audit records security-sensitive actions to the append-only
+// audit_events table.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+const (
+	ActionLogin               = "auth.login"
+	ActionLoginFailed         = "auth.login_failed"
+	ActionRefresh             = "auth.refresh"
+	ActionRefreshFailed       = "auth.refresh_failed"
+	ActionRevoke              = "auth.revoke"
+	ActionCredentialsUpdate   = "user.credentials_update"
+	ActionWebhookUpgrade      = "webhook.user_upgraded"
+	ActionWebhookSubscription = "webhook.subscription_changed"
+	ActionChirpDelete         = "chirp.delete"
+)
+
+type Event struct {
+	ActorID    uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Metadata   map[string]any
+}
+
+type Recorder struct {
+	db *database.Queries
+}
+
+func NewRecorder(db *database.Queries) *Recorder {
+	return &Recorder{db: db}
+}
+
+// Record writes e along with the caller's address and user agent. Failures
+// are logged and returned, but callers generally should not fail the
+// request because the audit write failed.
+func (rec *Recorder) Record(ctx context.Context, r *http.Request, e Event) error {
+	err := Write(ctx, rec.db, r, e)
+	if err != nil {
+		log.Printf("error recording audit event %s: %s", e.Action, err)
+	}
+	return err
+}
+
+// Write records e through q, so an event can be committed or rolled back
+// together with the change it describes.
+func Write(ctx context.Context, q *database.Queries, r *http.Request, e Event) error {
+	metadata := []byte("{}")
+	if len(e.Metadata) > 0 {
+		dat, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return fmt.Errorf("marshalling audit metadata: %w", err)
+		}
+		metadata = dat
+	}
+	params := database.CreateAuditEventParams{
+		ActorID:    uuid.NullUUID{UUID: e.ActorID, Valid: e.ActorID != uuid.Nil},
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Metadata:   metadata,
+	}
+	if r != nil {
+		params.IpAddress = RequestIP(r)
+		params.UserAgent = r.UserAgent()
+	}
+	return q.CreateAuditEvent(ctx, params)
+}
+
+// RequestIP returns the host portion of the request's remote address.
+// Forwarding headers are ignored because they are client controlled.
+func RequestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func TestWrite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	actor := uuid.New()
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(actor, ActionLogin, "user", actor.String(), "192.0.2.1", "test-agent", []byte(`{"method":"password"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Events without an actor, like a failed login, store a NULL actor and
+	// empty metadata.
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(nil, ActionLoginFailed, "user", "", "", "", []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+	q := database.New(db)
+	err = Write(context.Background(), q, r, Event{
+		ActorID:    actor,
+		Action:     ActionLogin,
+		TargetType: "user",
+		TargetID:   actor.String(),
+		Metadata:   map[string]any{"method": "password"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(context.Background(), q, nil, Event{Action: ActionLoginFailed, TargetType: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	for addr, want := range map[string]string{
+		"192.0.2.1:1234":   "192.0.2.1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"192.0.2.1":        "192.0.2.1",
+	} {
+		r.RemoteAddr = addr
+		if got := RequestIP(r); got != want {
+			t.Errorf("RequestIP(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import "fmt"
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRank[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Includes reports whether r grants at least the privileges of required.
+// Admins include moderators, and moderators include regular users.
+func (r Role) Includes(required Role) bool {
+	have, ok := roleRank[r]
+	if !ok {
+		return false
+	}
+	return have >= roleRank[required]
+}
@@ -0,0 +1,41 @@
+package auth
+
+import "testing"
+
+func TestRoles(t *testing.T) {
+	t.Run("Parse Known Role", func(t *testing.T) {
+		role, err := ParseRole("moderator")
+		if err != nil {
+			t.Fatalf("failed to parse role: %v", err)
+		}
+		if role != RoleModerator {
+			t.Errorf("expected %v, got %v", RoleModerator, role)
+		}
+	})
+
+	t.Run("Parse Unknown Role", func(t *testing.T) {
+		if _, err := ParseRole("superuser"); err == nil {
+			t.Error("expected error for unknown role, but got none")
+		}
+	})
+
+	t.Run("Hierarchy", func(t *testing.T) {
+		cases := []struct {
+			have     Role
+			required Role
+			want     bool
+		}{
+			{RoleAdmin, RoleModerator, true},
+			{RoleAdmin, RoleUser, true},
+			{RoleModerator, RoleModerator, true},
+			{RoleModerator, RoleAdmin, false},
+			{RoleUser, RoleModerator, false},
+			{Role(""), RoleUser, false},
+		}
+		for _, c := range cases {
+			if got := c.have.Includes(c.required); got != c.want {
+				t.Errorf("%q.Includes(%q) = %v, want %v", c.have, c.required, got, c.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeaders(secret string, ts int64, body []byte) http.Header {
+	h := http.Header{}
+	h.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	h.Set(WebhookSignatureHeader, "v1="+SignWebhook(secret, ts, body))
+	return h
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"abc"}}`)
+	now := time.Unix(1_700_000_000, 0)
+	newVerifier := func(secrets ...string) *WebhookVerifier {
+		v := NewWebhookVerifier(secrets, 5*time.Minute)
+		v.now = func() time.Time { return now }
+		return v
+	}
+
+	t.Run("Valid Signature", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		if err := v.Verify(signedHeaders("secret-a", now.Unix(), body), body); err != nil {
+			t.Fatalf("expected valid signature, got %v", err)
+		}
+	})
+
+	t.Run("Rotated Secret", func(t *testing.T) {
+		v := newVerifier("secret-new", "secret-old")
+		if err := v.Verify(signedHeaders("secret-old", now.Unix(), body), body); err != nil {
+			t.Fatalf("expected old secret to still verify, got %v", err)
+		}
+	})
+
+	t.Run("Multiple Signatures In Header", func(t *testing.T) {
+		v := newVerifier("secret-new")
+		h := signedHeaders("secret-old", now.Unix(), body)
+		h.Set(WebhookSignatureHeader, h.Get(WebhookSignatureHeader)+",v1="+SignWebhook("secret-new", now.Unix(), body))
+		if err := v.Verify(h, body); err != nil {
+			t.Fatalf("expected one matching signature to verify, got %v", err)
+		}
+	})
+
+	t.Run("Wrong Secret", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		err := v.Verify(signedHeaders("secret-b", now.Unix(), body), body)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("expected ErrInvalidSignature, got %v", err)
+		}
+	})
+
+	t.Run("Tampered Body", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		err := v.Verify(signedHeaders("secret-a", now.Unix(), body), []byte(`{"event":"user.upgraded"}`))
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("expected ErrInvalidSignature, got %v", err)
+		}
+	})
+
+	t.Run("Stale Timestamp", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		ts := now.Add(-10 * time.Minute).Unix()
+		err := v.Verify(signedHeaders("secret-a", ts, body), body)
+		if !errors.Is(err, ErrTimestampOutOfSkew) {
+			t.Errorf("expected ErrTimestampOutOfSkew, got %v", err)
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		h := signedHeaders("secret-a", now.Unix(), body)
+		if err := v.Verify(h, body); err != nil {
+			t.Fatalf("first delivery failed: %v", err)
+		}
+		if err := v.Verify(h, body); !errors.Is(err, ErrReplayedSignature) {
+			t.Errorf("expected ErrReplayedSignature, got %v", err)
+		}
+	})
+
+	t.Run("Missing Headers", func(t *testing.T) {
+		v := newVerifier("secret-a")
+		if err := v.Verify(http.Header{}, body); !errors.Is(err, ErrMissingSignature) {
+			t.Errorf("expected ErrMissingSignature, got %v", err)
+		}
+	})
+}
+
+func TestAPIKeyMatches(t *testing.T) {
+	keys := []string{"current-key", "previous-key"}
+	if !APIKeyMatches("previous-key", keys) {
+		t.Error("expected previous key to match")
+	}
+	if APIKeyMatches("wrong-key", keys) {
+		t.Error("expected wrong key not to match")
+	}
+	if APIKeyMatches("", []string{""}) {
+		t.Error("expected empty key never to match")
+	}
+}
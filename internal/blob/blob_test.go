@@ -0,0 +1,59 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("round trips a blob", func(t *testing.T) {
+		if err := store.Put(ctx, "media/a/original.png", strings.NewReader("data"), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+		rc, err := store.Get(ctx, "media/a/original.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		got, _ := io.ReadAll(rc)
+		if string(got) != "data" {
+			t.Errorf("got %q, want data", got)
+		}
+	})
+
+	t.Run("missing blobs are ErrNotFound", func(t *testing.T) {
+		if _, err := store.Get(ctx, "media/missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("err = %v, want ErrNotFound", err)
+		}
+		if err := store.Delete(ctx, "media/missing"); err != nil {
+			t.Errorf("deleting a missing blob: %v", err)
+		}
+	})
+
+	t.Run("delete removes the blob", func(t *testing.T) {
+		store.Put(ctx, "media/b", strings.NewReader("x"), "")
+		if err := store.Delete(ctx, "media/b"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get(ctx, "media/b"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("rejects keys escaping the root", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", `a\b`} {
+			if err := store.Put(ctx, key, strings.NewReader("x"), ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put(%q) err = %v, want ErrInvalidKey", key, err)
+			}
+		}
+	})
+}
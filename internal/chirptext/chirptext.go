@@ -0,0 +1,43 @@
+// Package chirptext measures chirp bodies the way people read them: in
+// user-perceived characters rather than bytes or code points, with links
+// counted at a fixed weight since clients display them shortened.
+package chirptext
+
+import (
+	"unicode"
+
+	"github.com/jcfullmer/chirpy/internal/entities"
+	"github.com/rivo/uniseg"
+)
+
+// URLWeight is what every link counts for, however long it is.
+const URLWeight = 23
+
+// Length returns the weighted length of body: each grapheme cluster counts
+// as one and each link as URLWeight.
+func Length(body string) int {
+	runes := []rune(body)
+	n := 0
+	prev := 0
+	for _, u := range entities.Parse(body).URLs {
+		n += Graphemes(string(runes[prev:u.Start])) + URLWeight
+		prev = u.End
+	}
+	return n + Graphemes(string(runes[prev:]))
+}
+
+// IsBlank reports whether s has nothing visible in it: only whitespace and
+// invisible format characters such as zero-width spaces.
+func IsBlank(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) && !unicode.Is(unicode.Cf, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Graphemes counts the extended grapheme clusters in s, following UAX #29.
+func Graphemes(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
@@ -0,0 +1,53 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := map[string]int{
+		"hello":                5,
+		"caf\u00e9":            4,
+		"cafe\u0301":           4,
+		"a\r\nb":               3,
+		"\U0001F44D\U0001F3FD": 1,
+		"\U0001F469\u200d\U0001F469\u200d\U0001F467": 1,
+		"\U0001F1E8\U0001F1E6\U0001F1EB\U0001F1F7":   2,
+		"\U0001F1E8\U0001F1E6\U0001F1EB":             2,
+		"\u2764\ufe0f":                               1,
+		"\ud55c\uad6d\uc5b4":                         3,
+		"\u1100\u1161\u11a8":                         1,
+		"\U0001F3F4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f": 1,
+		// Prepend characters join what follows them.
+		"\u0600\u0661": 1,
+		// A ZWJ only joins emoji, and plain arrows aren't emoji.
+		"\u2192\u200d\u2192": 2,
+	}
+	for s, want := range tests {
+		if got := Graphemes(s); got != want {
+			t.Errorf("Graphemes(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	if got := Length(strings.Repeat("😀", 50)); got != 50 {
+		t.Errorf("50 emoji counted as %d", got)
+	}
+	body := "read https://example.com/" + strings.Repeat("a", 100) + " now"
+	if got, want := Length(body), len("read ")+URLWeight+len(" now"); got != want {
+		t.Errorf("Length = %d, want %d", got, want)
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	for _, s := range []string{"", "   ", "\n\t", "\u200b \u00a0"} {
+		if !IsBlank(s) {
+			t.Errorf("IsBlank(%q) = false", s)
+		}
+	}
+	if IsBlank(" a ") {
+		t.Error(`IsBlank(" a ") = true`)
+	}
+}
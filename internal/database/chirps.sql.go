@@ -7,29 +7,39 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
+SELECT
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    $1::text,
+    $2::uuid,
+    $3::uuid
+WHERE NOT EXISTS (
+    SELECT 1 FROM chirps AS parent
+    JOIN blocks ON (blocks.blocker_id = parent.user_id AND blocks.blocked_id = $2::uuid)
+        OR (blocks.blocker_id = $2::uuid AND blocks.blocked_id = parent.user_id)
+    WHERE parent.id = $3::uuid
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
 }
 
+// Replies across a block in either direction insert nothing, so the caller
+// sees sql.ErrNoRows.
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.ReplyToID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +47,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
 	)
 	return i, err
 }
@@ -52,7 +65,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at FROM chirps
 WHERE id = $1
 `
 
@@ -65,17 +78,40 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at FROM chirps
+WHERE hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = $1::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid)
+            OR (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM $1::uuid
+    )
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+// viewer_id may be NULL for anonymous callers, in which case only hidden
+// chirps and those that are shadowed or by shadow-banned or deactivated
+// authors are filtered. Authors still see their own shadowed chirps.
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +125,9 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.ShadowedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -102,3 +141,259 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getDatabaseTime = `-- name: GetDatabaseTime :one
+SELECT NOW()::timestamp AS now
+`
+
+// The clock chirp and user timestamps are written with, for comparing
+// against them.
+func (q *Queries) GetDatabaseTime(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getDatabaseTime)
+	var now time.Time
+	err := row.Scan(&now)
+	return now, err
+}
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at FROM chirps
+WHERE id = $1::uuid
+    AND hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = $2::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+            OR (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM $2::uuid
+    )
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+// Like GetChirpByID, but a chirp across a block from viewer_id, or one
+// that is shadowed or by a shadow-banned or deactivated author other than
+// viewer_id, is not found.
+// Muted authors' chirps can still be opened directly.
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :one
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, hideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
+	)
+	return i, err
+}
+
+const holdChirp = `-- name: HoldChirp :exec
+INSERT INTO held_chirps (chirp_id, announced, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (chirp_id) DO NOTHING
+`
+
+type HoldChirpParams struct {
+	ChirpID   uuid.UUID
+	Announced bool
+}
+
+func (q *Queries) HoldChirp(ctx context.Context, arg HoldChirpParams) error {
+	_, err := q.db.ExecContext(ctx, holdChirp, arg.ChirpID, arg.Announced)
+	return err
+}
+
+const listRecentChirpsByUser = `-- name: ListRecentChirpsByUser :many
+SELECT id, body, created_at FROM chirps
+WHERE user_id = $1::uuid
+    AND created_at > NOW() - make_interval(secs => $2::float8)
+ORDER BY created_at DESC
+LIMIT 200
+`
+
+type ListRecentChirpsByUserParams struct {
+	UserID        uuid.UUID
+	WindowSeconds float64
+}
+
+type ListRecentChirpsByUserRow struct {
+	ID        uuid.UUID
+	Body      string
+	CreatedAt time.Time
+}
+
+// Feeds spam scoring, which only needs enough history to spot repeats.
+func (q *Queries) ListRecentChirpsByUser(ctx context.Context, arg ListRecentChirpsByUserParams) ([]ListRecentChirpsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listRecentChirpsByUser, arg.UserID, arg.WindowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRecentChirpsByUserRow
+	for rows.Next() {
+		var i ListRecentChirpsByUserRow
+		if err := rows.Scan(&i.ID, &i.Body, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseHeldChirp = `-- name: ReleaseHeldChirp :one
+DELETE FROM held_chirps
+WHERE chirp_id = $1 AND EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = held_chirps.chirp_id AND chirps.hidden_at IS NULL AND chirps.shadowed_at IS NULL
+)
+RETURNING announced
+`
+
+// Ends the hold on a held chirp once it is visible to everyone.
+func (q *Queries) ReleaseHeldChirp(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, releaseHeldChirp, chirpID)
+	var announced bool
+	err := row.Scan(&announced)
+	return announced, err
+}
+
+const restoreHeldChirp = `-- name: RestoreHeldChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL, shadowed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND EXISTS (SELECT 1 FROM held_chirps WHERE held_chirps.chirp_id = chirps.id)
+`
+
+// Undoes the hold on a chirp spam scoring held back; chirps that weren't
+// held are left alone.
+func (q *Queries) RestoreHeldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreHeldChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const shadowChirp = `-- name: ShadowChirp :exec
+UPDATE chirps
+SET shadowed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ShadowChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, shadowChirp, id)
+	return err
+}
+
+const unhideChirp = `-- name: UnhideChirp :one
+UPDATE chirps
+SET hidden_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unhideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
+	)
+	return i, err
+}
+
+const unshadowChirp = `-- name: UnshadowChirp :one
+UPDATE chirps
+SET shadowed_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at
+`
+
+func (q *Queries) UnshadowChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unshadowChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, reply_to_id, shadowed_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.ShadowedAt,
+	)
+	return i, err
+}
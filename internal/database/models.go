@@ -6,17 +6,214 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AdminAction struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ActorID    uuid.UUID
+	ActorRole  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Reason     string
+}
+
+type Attachment struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int64
+	Width        int32
+	Height       int32
+	BlobKey      string
+	ThumbnailKey string
+	AltText      string
+	CreatedAt    time.Time
+}
+
+type AuditEvent struct {
+	ID         int64
+	CreatedAt  time.Time
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IpAddress  string
+	UserAgent  string
+	Metadata   json.RawMessage
+}
+
+type BlobDeletion struct {
+	Key           string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+	Position     int32
+	CreatedAt    time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	HashtagID   uuid.UUID
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpLink struct {
+	ChirpID  uuid.UUID
+	Url      string
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int32
+	EndOffset   int32
+}
+
 type Chirp struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	HiddenAt   sql.NullTime
+	ReplyToID  uuid.NullUUID
+	ShadowedAt sql.NullTime
+}
+
+type Draft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Body      string
+	ReplyToID uuid.NullUUID
+	Media     json.RawMessage
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type HeldChirp struct {
+	ChirpID   uuid.UUID
+	Announced bool
+	CreatedAt time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type LinkPreview struct {
+	Url           string
+	Status        string
+	Title         string
+	Description   string
+	ImageUrl      string
+	SiteName      string
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+	FetchedAt     sql.NullTime
+	CreatedAt     time.Time
+}
+
+type ModerationWord struct {
+	Word      string
+	Action    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID          uuid.UUID
+	RecipientID uuid.UUID
+	ActorID     uuid.UUID
+	Type        string
+	ChirpID     uuid.NullUUID
+	CreatedAt   time.Time
+	ReadAt      sql.NullTime
+}
+
+type OutboxEvent struct {
+	ID            int64
+	AggregateType string
+	AggregateID   uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+	PublishedAt   sql.NullTime
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     string
+	Txid          int64
+}
+
+type OutboxSinkDelivery struct {
+	EventID     int64
+	Sink        string
+	PublishedAt time.Time
+}
+
+type PinnedChirp struct {
 	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	Position  int32
+	CreatedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +225,111 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 }
 
+type ReportEvent struct {
+	ID        int64
+	ReportID  uuid.UUID
+	ActorID   uuid.NullUUID
+	FromState sql.NullString
+	ToState   string
+	Note      string
+	CreatedAt time.Time
+}
+
+type Report struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ReporterID     uuid.NullUUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Reason         string
+	Details        string
+	State          string
+	AssigneeID     uuid.NullUUID
+	Resolution     sql.NullString
+	ResolvedAt     sql.NullTime
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ReplyToID uuid.NullUUID
+	Media     json.RawMessage
+	PublishAt time.Time
+	State     string
+	ChirpID   uuid.NullUUID
+	LastError sql.NullString
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Legacy           bool
+}
+
+type UserWarning struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	ModeratorID uuid.NullUUID
+	ReportID    uuid.NullUUID
+	Reason      string
+	CreatedAt   time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	Role           string
+	Handle         sql.NullString
+	SuspendedUntil sql.NullTime
+	AccountState   string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	OutboxEventID  int64
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	OwnerID   uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WebhookEvent struct {
+	ID          string
+	Source      string
+	Event       string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	LastError   string
+	ReceivedAt  time.Time
+	UpdatedAt   time.Time
+	ProcessedAt sql.NullTime
 }
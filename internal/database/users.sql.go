@@ -7,26 +7,30 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    $1::text,
+    $2::text,
+    $3::text
 )
-RETURNING id, created_at, updated_at, email
+RETURNING id, created_at, updated_at, email, handle
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 type CreateUserRow struct {
@@ -34,16 +38,18 @@ type CreateUserRow struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Email     string
+	Handle    sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i CreateUserRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
+		&i.Handle,
 	)
 	return i, err
 }
@@ -57,8 +63,196 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getMentionableUsersByHandles = `-- name: GetMentionableUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state FROM users
+WHERE handle = ANY($1::text[])
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = users.id AND blocks.blocked_id = $2::uuid)
+            OR (blocks.blocker_id = $2::uuid AND blocks.blocked_id = users.id)
+    )
+`
+
+type GetMentionableUsersByHandlesParams struct {
+	Handles  []string
+	AuthorID uuid.UUID
+}
+
+// Users on either side of a block with author_id can't be mentioned by
+// them.
+func (q *Queries) GetMentionableUsersByHandles(ctx context.Context, arg GetMentionableUsersByHandlesParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getMentionableUsersByHandles, pq.Array(arg.Handles), arg.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Role,
+			&i.Handle,
+			&i.SuspendedUntil,
+			&i.AccountState,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
+	)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state FROM users
+WHERE handle = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Role,
+			&i.Handle,
+			&i.SuspendedUntil,
+			&i.AccountState,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, role, handle, account_state, suspended_until,
+    EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE subscriptions.user_id = users.id
+          AND (
+            (subscriptions.status = 'active' AND subscriptions.legacy AND subscriptions.current_period_end IS NULL)
+            OR (subscriptions.status IN ('active', 'past_due', 'canceled') AND subscriptions.current_period_end > NOW())
+          )
+    ) AS is_chirpy_red
+FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32
+	Offset int32
+}
+
+type ListUsersRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	Role           string
+	Handle         sql.NullString
+	AccountState   string
+	SuspendedUntil sql.NullTime
+	IsChirpyRed    bool
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Role,
+			&i.Handle,
+			&i.AccountState,
+			&i.SuspendedUntil,
+			&i.IsChirpyRed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const loginUser = `-- name: LoginUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state FROM users
 WHERE email = $1
 `
 
@@ -71,16 +265,130 @@ func (q *Queries) LoginUser(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
 	)
 	return i, err
 }
 
+const setAccountState = `-- name: SetAccountState :one
+UPDATE users
+SET account_state = $1::text,
+    suspended_until = $2::timestamptz,
+    updated_at = NOW()
+WHERE id = $3::uuid
+RETURNING id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state
+`
+
+type SetAccountStateParams struct {
+	AccountState   string
+	SuspendedUntil sql.NullTime
+	ID             uuid.UUID
+}
+
+// suspended_until only means something for the suspended state and is
+// cleared otherwise.
+func (q *Queries) SetAccountState(ctx context.Context, arg SetAccountStateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setAccountState, arg.AccountState, arg.SuspendedUntil, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
+	)
+	return i, err
+}
+
+const setUserHandle = `-- name: SetUserHandle :one
+UPDATE users
+SET handle = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state
+`
+
+type SetUserHandleParams struct {
+	Handle sql.NullString
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetUserHandle(ctx context.Context, arg SetUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserHandle, arg.Handle, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
+	)
+	return i, err
+}
+
+const setUserRoleByEmail = `-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE email = $2
+`
+
+type SetUserRoleByEmailParams struct {
+	Role  string
+	Email string
+}
+
+func (q *Queries) SetUserRoleByEmail(ctx context.Context, arg SetUserRoleByEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRoleByEmail, arg.Role, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, role, handle, suspended_until, account_state
 `
 
 type UpdateUserParams struct {
@@ -98,18 +406,10 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Role,
+		&i.Handle,
+		&i.SuspendedUntil,
+		&i.AccountState,
 	)
 	return i, err
 }
-
-const upgradeUser = `-- name: UpgradeUser :exec
-UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1
-`
-
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUser, id)
-	return err
-}
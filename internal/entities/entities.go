@@ -0,0 +1,188 @@
+// Package entities extracts #hashtags, @mentions and links from chirp
+// bodies.
+//
+// Offsets are Unicode code point indexes into the body: Start points at the
+// leading '#' or '@' and End is exclusive, so body[Start:End] (as runes) is
+// the entity's original text.
+package entities
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxTagLength        = 100
+	minHandleLength     = 3
+	maxHandleLength     = 15
+	hashtagMarker       = '#'
+	mentionMarker       = '@'
+	fullwidthHashMarker = '＃'
+)
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Mention struct {
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+type URL struct {
+	URL   string `json:"url"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+	URLs     []URL     `json:"urls"`
+}
+
+// NormalizeTag returns the canonical form tags are stored and looked up by.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimLeft(tag, "#＃"))
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+func isHandleRune(r rune) bool {
+	return r < unicode.MaxASCII && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// urlAt returns the end of an http(s) URL starting at runes[i], or -1.
+// Trailing punctuation is left out, and so is a closing parenthesis that
+// has no opening partner inside the URL.
+func urlAt(runes []rune, i int) int {
+	rest := strings.ToLower(string(runes[i:min(i+8, len(runes))]))
+	if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+		return -1
+	}
+	end := i
+	for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`<>"`, runes[end]) {
+		end++
+	}
+	for end > i {
+		last := runes[end-1]
+		if strings.ContainsRune(".,;:!?'", last) {
+			end--
+			continue
+		}
+		if last == ')' && strings.Count(string(runes[i:end]), "(") < strings.Count(string(runes[i:end]), ")") {
+			end--
+			continue
+		}
+		break
+	}
+	u, err := url.Parse(string(runes[i:end]))
+	if err != nil || u.Host == "" {
+		return -1
+	}
+	return end
+}
+
+// Parse finds every hashtag, mention and link in body. An entity must not
+// be glued to a preceding word character, so "a#b" and "me@example.com" are
+// ignored, and nothing inside a link is parsed further. Hashtags need at
+// least one letter; handles are 3-15 ASCII letters, digits or underscores,
+// and longer runs are not mentions at all.
+func Parse(body string) Entities {
+	ents := Entities{Hashtags: []Hashtag{}, Mentions: []Mention{}, URLs: []URL{}}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if (r == 'h' || r == 'H') && (i == 0 || !isTagRune(runes[i-1])) {
+			if end := urlAt(runes, i); end > 0 {
+				ents.URLs = append(ents.URLs, URL{URL: string(runes[i:end]), Start: i, End: end})
+				i = end - 1
+				continue
+			}
+		}
+		if r != hashtagMarker && r != fullwidthHashMarker && r != mentionMarker {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == mentionMarker || runes[i-1] == hashtagMarker) {
+			continue
+		}
+		if r == mentionMarker {
+			end := i + 1
+			for end < len(runes) && isHandleRune(runes[end]) {
+				end++
+			}
+			n := end - i - 1
+			if n >= minHandleLength && n <= maxHandleLength && (end == len(runes) || !isTagRune(runes[end])) {
+				ents.Mentions = append(ents.Mentions, Mention{
+					Handle: strings.ToLower(string(runes[i+1 : end])),
+					Start:  i,
+					End:    end,
+				})
+			}
+			i = end - 1
+			continue
+		}
+		end := i + 1
+		hasLetter := false
+		for end < len(runes) && isTagRune(runes[end]) {
+			if unicode.IsLetter(runes[end]) {
+				hasLetter = true
+			}
+			end++
+		}
+		if hasLetter && end-i-1 <= maxTagLength {
+			ents.Hashtags = append(ents.Hashtags, Hashtag{
+				Tag:   NormalizeTag(string(runes[i+1 : end])),
+				Start: i,
+				End:   end,
+			})
+		}
+		i = end - 1
+	}
+	return ents
+}
+
+// Tags returns the distinct normalized hashtags in order of appearance.
+func (e Entities) Tags() []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, h := range e.Hashtags {
+		if !seen[h.Tag] {
+			seen[h.Tag] = true
+			tags = append(tags, h.Tag)
+		}
+	}
+	return tags
+}
+
+// Handles returns the distinct mentioned handles in order of appearance.
+func (e Entities) Handles() []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, m := range e.Mentions {
+		if !seen[m.Handle] {
+			seen[m.Handle] = true
+			handles = append(handles, m.Handle)
+		}
+	}
+	return handles
+}
+
+// Links returns the distinct URLs in order of appearance.
+func (e Entities) Links() []string {
+	seen := map[string]bool{}
+	links := []string{}
+	for _, u := range e.URLs {
+		if !seen[u.URL] {
+			seen[u.URL] = true
+			links = append(links, u.URL)
+		}
+	}
+	return links
+}
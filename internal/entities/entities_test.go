@@ -0,0 +1,94 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		hashtags []Hashtag
+		mentions []Mention
+		urls     []URL
+	}{
+		{
+			name:     "hashtag and mention",
+			body:     "hi @Alice check #GoLang",
+			hashtags: []Hashtag{{Tag: "golang", Start: 16, End: 23}},
+			mentions: []Mention{{Handle: "alice", Start: 3, End: 9}},
+		},
+		{
+			name:     "offsets count code points",
+			body:     "héllo #café",
+			hashtags: []Hashtag{{Tag: "café", Start: 6, End: 11}},
+			mentions: []Mention{},
+		},
+		{
+			name:     "email addresses are not mentions",
+			body:     "mail me@example.com",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+		},
+		{
+			name:     "handles out of range are ignored",
+			body:     "@ab @abcdefghijklmnop @ok_go",
+			hashtags: []Hashtag{},
+			mentions: []Mention{{Handle: "ok_go", Start: 22, End: 28}},
+		},
+		{
+			name:     "numeric and glued hashtags are ignored",
+			body:     "#1 a#b ##x #2024_wrap",
+			hashtags: []Hashtag{{Tag: "2024_wrap", Start: 11, End: 21}},
+			mentions: []Mention{},
+		},
+		{
+			name:     "punctuation ends entities",
+			body:     "(#go), @bob!",
+			hashtags: []Hashtag{{Tag: "go", Start: 1, End: 4}},
+			mentions: []Mention{{Handle: "bob", Start: 7, End: 11}},
+		},
+		{
+			name:     "links hide their fragments and paths",
+			body:     "see https://example.com/@x#frag, then #go",
+			hashtags: []Hashtag{{Tag: "go", Start: 38, End: 41}},
+			mentions: []Mention{},
+			urls:     []URL{{URL: "https://example.com/@x#frag", Start: 4, End: 31}},
+		},
+		{
+			name:     "unbalanced closing paren is not part of a link",
+			body:     "(http://a.example/wiki/Go_(lang))",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls:     []URL{{URL: "http://a.example/wiki/Go_(lang)", Start: 1, End: 32}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got.Hashtags, tt.hashtags) {
+				t.Errorf("hashtags = %+v, want %+v", got.Hashtags, tt.hashtags)
+			}
+			if !reflect.DeepEqual(got.Mentions, tt.mentions) {
+				t.Errorf("mentions = %+v, want %+v", got.Mentions, tt.mentions)
+			}
+			if tt.urls == nil {
+				tt.urls = []URL{}
+			}
+			if !reflect.DeepEqual(got.URLs, tt.urls) {
+				t.Errorf("urls = %+v, want %+v", got.URLs, tt.urls)
+			}
+		})
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	e := Parse("#Go #go @Bob @bob #rust")
+	if got := e.Tags(); !reflect.DeepEqual(got, []string{"go", "rust"}) {
+		t.Errorf("Tags() = %v", got)
+	}
+	if got := e.Handles(); !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Errorf("Handles() = %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+// Package entitlements maps subscription plans to the features and limits
+// they unlock, so handlers ask "may this user do X" instead of checking
+// plan names directly.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	FreePlan = "free"
+	RedPlan  = "red"
+)
+
+type Entitlements struct {
+	MaxChirpLength      int     `json:"max_chirp_length"`
+	CanEditChirps       bool    `json:"can_edit_chirps"`
+	CanScheduleChirps   bool    `json:"can_schedule_chirps"`
+	RateLimitMultiplier float64 `json:"rate_limit_multiplier"`
+}
+
+type Catalog struct {
+	plans map[string]Entitlements
+}
+
+func DefaultCatalog() *Catalog {
+	return &Catalog{plans: map[string]Entitlements{
+		FreePlan: {
+			MaxChirpLength:      140,
+			RateLimitMultiplier: 1,
+		},
+		RedPlan: {
+			MaxChirpLength:      560,
+			CanEditChirps:       true,
+			CanScheduleChirps:   true,
+			RateLimitMultiplier: 5,
+		},
+	}}
+}
+
+// Load reads a JSON object keyed by plan name. Each plan in the file is
+// merged field by field over its default, or over the free plan for new
+// plans, so a file only needs the limits it changes. Plans missing from
+// the file keep their defaults.
+func Load(path string) (*Catalog, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plans := map[string]json.RawMessage{}
+	if err := json.Unmarshal(dat, &plans); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	catalog := DefaultCatalog()
+	base := catalog.plans[FreePlan]
+	for name, raw := range plans {
+		e, ok := catalog.plans[name]
+		if !ok {
+			e = base
+		}
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("parsing %s: plan %q: %w", path, name, err)
+		}
+		if e.MaxChirpLength <= 0 {
+			return nil, fmt.Errorf("plan %q: max_chirp_length must be positive", name)
+		}
+		if e.RateLimitMultiplier <= 0 {
+			return nil, fmt.Errorf("plan %q: rate_limit_multiplier must be positive", name)
+		}
+		catalog.plans[name] = e
+	}
+	return catalog, nil
+}
+
+// For returns the entitlements of plan, falling back to the free plan for
+// unknown plans.
+func (c *Catalog) For(plan string) Entitlements {
+	if e, ok := c.plans[plan]; ok {
+		return e
+	}
+	return c.plans[FreePlan]
+}
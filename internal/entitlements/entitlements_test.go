@@ -0,0 +1,73 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		c := DefaultCatalog()
+		if got := c.For(FreePlan).MaxChirpLength; got != 140 {
+			t.Errorf("expected free max length 140, got %d", got)
+		}
+		if !c.For(RedPlan).CanEditChirps {
+			t.Error("expected red plan to allow editing")
+		}
+	})
+
+	t.Run("Unknown Plan Falls Back To Free", func(t *testing.T) {
+		c := DefaultCatalog()
+		if c.For("platinum") != c.For(FreePlan) {
+			t.Error("expected unknown plan to resolve to free plan")
+		}
+	})
+
+	t.Run("Load Overrides", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plans.json")
+		data := `{"red": {"max_chirp_length": 1000, "can_edit_chirps": true}, "team": {"max_chirp_length": 2000}}`
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("failed to load catalog: %v", err)
+		}
+		if got := c.For(RedPlan).MaxChirpLength; got != 1000 {
+			t.Errorf("expected red max length 1000, got %d", got)
+		}
+		if !c.For(RedPlan).CanScheduleChirps {
+			t.Error("expected overridden red plan to keep scheduling")
+		}
+		if got := c.For(RedPlan).RateLimitMultiplier; got != 5 {
+			t.Errorf("expected red plan to keep multiplier 5, got %v", got)
+		}
+		if got := c.For("team").RateLimitMultiplier; got != 1 {
+			t.Errorf("expected new plan to inherit free multiplier 1, got %v", got)
+		}
+		if got := c.For(FreePlan).MaxChirpLength; got != 140 {
+			t.Errorf("expected free plan to keep default, got %d", got)
+		}
+	})
+
+	t.Run("Reject Invalid Multiplier", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plans.json")
+		if err := os.WriteFile(path, []byte(`{"red": {"rate_limit_multiplier": 0}}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Error("expected error for zero multiplier, but got none")
+		}
+	})
+
+	t.Run("Reject Invalid Length", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plans.json")
+		if err := os.WriteFile(path, []byte(`{"free": {"max_chirp_length": 0}}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Error("expected error for zero max length, but got none")
+		}
+	})
+}
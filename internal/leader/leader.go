@@ -0,0 +1,76 @@
+// Package leader elects one instance among many to run a background job.
+// Leadership is a Postgres session-level advisory lock held on a dedicated
+// connection: it lasts exactly as long as that session, so an instance
+// that crashes or loses its connection hands over without any cleanup.
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+)
+
+// Key derives an advisory lock key from a job name so every job gets its
+// own lock without a registry of magic numbers.
+func Key(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+type Elector struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewElector(db *sql.DB, key int64) *Elector {
+	return &Elector{db: db, key: key}
+}
+
+// IsLeader reports whether this instance holds the lock, trying to take it
+// when it doesn't. Call it before each round of work: a leader whose
+// connection has died finds out here and stops.
+func (e *Elector) IsLeader(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn != nil {
+		if err := e.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		e.conn.Close()
+		e.conn = nil
+	}
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+	e.conn = conn
+	return true, nil
+}
+
+// Release gives up leadership, if held, so another instance can take over
+// without waiting for this one's session to end.
+func (e *Elector) Release(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	_, err := e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", e.key)
+	e.conn.Close()
+	e.conn = nil
+	return err
+}
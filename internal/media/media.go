@@ -0,0 +1,337 @@
+// Package media validates and normalizes uploaded images. Every upload is
+// decoded and re-encoded, which drops EXIF, XMP and comment metadata (GPS
+// coordinates included) because the standard library encoders never write
+// it. The EXIF orientation is applied to the pixels first so photos still
+// display the right way up.
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	MaxDimension   = 10000
+	MaxPixels      = 25_000_000
+	MaxGIFFrames   = 300
+	MaxGIFPixels   = 100_000_000 // all frames together
+	ThumbnailSize  = 320
+	jpegQuality    = 85
+	exifHeader     = "Exif\x00\x00"
+	orientationTag = 0x0112
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+
+	errMalformedGIF = errors.New("gif: malformed block structure")
+)
+
+// AllowedTypes are the sniffed content types accepted for upload.
+var AllowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+type Result struct {
+	Original  Image
+	Thumbnail Image
+}
+
+// Sniff returns the content type of data judged by its bytes, ignoring
+// whatever the client claimed.
+func Sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// Process sniffs, bounds-checks, strips and re-encodes an uploaded image and
+// renders a thumbnail no larger than ThumbnailSize on either side.
+func Process(data []byte) (Result, error) {
+	contentType := Sniff(data)
+	if !AllowedTypes[contentType] {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension || cfg.Width*cfg.Height > MaxPixels {
+		return Result{}, fmt.Errorf("%w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	var original bytes.Buffer
+	var first image.Image
+	switch contentType {
+	case "image/gif":
+		// DecodeAll allocates every frame up front, so count them first.
+		frames, err := gifFrames(data)
+		if err != nil {
+			return Result{}, err
+		}
+		if frames > MaxGIFFrames || frames*cfg.Width*cfg.Height > MaxGIFPixels {
+			return Result{}, fmt.Errorf("%w: %d frames of %dx%d", ErrTooLarge, frames, cfg.Width, cfg.Height)
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Result{}, err
+		}
+		if err := gif.EncodeAll(&original, g); err != nil {
+			return Result{}, err
+		}
+		first = g.Image[0]
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Result{}, err
+		}
+		first = applyOrientation(img, jpegOrientation(data))
+		if err := jpeg.Encode(&original, first, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Result{}, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Result{}, err
+		}
+		first = img
+		if err := png.Encode(&original, img); err != nil {
+			return Result{}, err
+		}
+	}
+
+	thumb := thumbnail(first, ThumbnailSize)
+	var thumbBuf bytes.Buffer
+	thumbType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbType = "image/jpeg"
+		err = jpeg.Encode(&thumbBuf, thumb, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&thumbBuf, thumb)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	bounds := first.Bounds()
+	return Result{
+		Original: Image{
+			Data:        original.Bytes(),
+			ContentType: contentType,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+		},
+		Thumbnail: Image{
+			Data:        thumbBuf.Bytes(),
+			ContentType: thumbType,
+			Width:       thumb.Bounds().Dx(),
+			Height:      thumb.Bounds().Dy(),
+		},
+	}, nil
+}
+
+// gifFrames counts the image descriptors in a GIF by walking its blocks
+// without decompressing anything. It stops counting once past
+// MaxGIFFrames.
+func gifFrames(data []byte) (int, error) {
+	// Header and logical screen descriptor, then the global color table.
+	if len(data) < 13 {
+		return 0, errMalformedGIF
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+	// skipSubBlocks moves i past a run of data sub-blocks.
+	skipSubBlocks := func() bool {
+		for i < len(data) {
+			n := int(data[i])
+			i += 1 + n
+			if n == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label, then sub-blocks
+			i += 2
+			if !skipSubBlocks() {
+				return 0, errMalformedGIF
+			}
+		case 0x2C: // image descriptor, local color table, LZW code size
+			if i+10 > len(data) {
+				return 0, errMalformedGIF
+			}
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&7 + 1)
+			}
+			i++
+			if !skipSubBlocks() {
+				return 0, errMalformedGIF
+			}
+			frames++
+			if frames > MaxGIFFrames {
+				return frames, nil
+			}
+		case 0x3B: // trailer
+			return frames, nil
+		default:
+			return 0, errMalformedGIF
+		}
+	}
+	// Decoders tolerate a missing trailer; so does this.
+	return frames, nil
+}
+
+// jpegOrientation reads the EXIF orientation (1-8) from a JPEG's APP1
+// segment, returning 1 when there is none or it can't be parsed.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte(exifHeader)) {
+			return tiffOrientation(segment[len(exifHeader):])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms img so that it displays upright without the
+// EXIF orientation tag.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// thumbnail downscales img to fit within size x size using a box filter.
+// Images that already fit are returned unchanged.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	scale := float64(max(w, h)) / float64(size)
+	dw, dh := max(1, int(float64(w)/scale+0.5)), max(1, int(float64(h)/scale+0.5))
+	dst := image.NewRGBA64(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*h/dh, max((dy+1)*h/dh, dy*h/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*w/dw, max((dx+1)*w/dw, dx*w/dw+1)
+			var r, g, bl, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(dx, dy, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying orientation right
+// after the JPEG SOI marker.
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte{'M', 'M', 0, 0x2A, 0, 0, 0, 8, 0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0,
+		0, 0, 0, 0}
+	payload := append([]byte(exifHeader), tiff...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("rejects non-images", func(t *testing.T) {
+		_, err := Process([]byte("<html>definitely not a png</html>"))
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("err = %v, want ErrUnsupportedType", err)
+		}
+	})
+
+	t.Run("png gets a bounded thumbnail", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, testImage(640, 320))
+		res, err := Process(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Original.ContentType != "image/png" || res.Original.Width != 640 || res.Original.Height != 320 {
+			t.Errorf("original = %s %dx%d", res.Original.ContentType, res.Original.Width, res.Original.Height)
+		}
+		if res.Thumbnail.Width != ThumbnailSize || res.Thumbnail.Height != ThumbnailSize/2 {
+			t.Errorf("thumbnail = %dx%d", res.Thumbnail.Width, res.Thumbnail.Height)
+		}
+	})
+
+	t.Run("jpeg EXIF is stripped and orientation applied", func(t *testing.T) {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, testImage(40, 20), nil)
+		src := withOrientation(buf.Bytes(), 6)
+		if jpegOrientation(src) != 6 {
+			t.Fatalf("test fixture orientation = %d", jpegOrientation(src))
+		}
+		res, err := Process(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(res.Original.Data, []byte("Exif")) {
+			t.Error("EXIF survived re-encoding")
+		}
+		if res.Original.Width != 20 || res.Original.Height != 40 {
+			t.Errorf("rotated size = %dx%d, want 20x40", res.Original.Width, res.Original.Height)
+		}
+	})
+
+	t.Run("rejects oversized dimensions", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, MaxDimension+1, 1)))
+		if _, err := Process(buf.Bytes()); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("err = %v, want ErrTooLarge", err)
+		}
+	})
+
+	t.Run("gif frames are counted before decoding", func(t *testing.T) {
+		g := &gif.GIF{}
+		for i := 0; i < 3; i++ {
+			g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}))
+			g.Delay = append(g.Delay, 0)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := gifFrames(buf.Bytes()); err != nil || n != 3 {
+			t.Fatalf("gifFrames = %d, %v; want 3", n, err)
+		}
+		if _, err := Process(buf.Bytes()); err != nil {
+			t.Errorf("small gif rejected: %v", err)
+		}
+	})
+
+	t.Run("rejects gifs with too many frames", func(t *testing.T) {
+		g := &gif.GIF{}
+		frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black})
+		for i := 0; i <= MaxGIFFrames; i++ {
+			g.Image = append(g.Image, frame)
+			g.Delay = append(g.Delay, 0)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Process(buf.Bytes()); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("err = %v, want ErrTooLarge", err)
+		}
+	})
+
+	t.Run("rejects gifs too large across frames", func(t *testing.T) {
+		g := &gif.GIF{}
+		frame := image.NewPaletted(image.Rect(0, 0, 5000, 5000), color.Palette{color.Black})
+		for i := 0; i < 5; i++ {
+			g.Image = append(g.Image, frame)
+			g.Delay = append(g.Delay, 0)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Process(buf.Bytes()); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("err = %v, want ErrTooLarge", err)
+		}
+	})
+
+	t.Run("rejects truncated gifs", func(t *testing.T) {
+		if _, err := gifFrames([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x2c\x00")); err == nil {
+			t.Error("expected error for truncated gif, but got none")
+		}
+	})
+}
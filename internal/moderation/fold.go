@@ -0,0 +1,68 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// foldMap sends accented Latin letters to their base letter, look-alike
+// Cyrillic and Greek letters to the Latin letter they imitate, and leetspeak
+// digits and symbols to the letter they stand for. 'l' and '1' both fold to
+// 'i' because all three are used interchangeably.
+var foldMap = buildFoldMap(map[rune]string{
+	'a': "àáâãäåāăąǎȁȃаα4@",
+	'b': "ƀьвβ8",
+	'c': "çćĉċčсϲ",
+	'd': "ďđ",
+	'e': "èéêëēĕėęěȅȇеєε3",
+	'g': "ĝğġģ9",
+	'h': "ĥħһн",
+	'i': "ìíîïĩīĭįıǐȉȋіїιl1!|ĺļľŀłӏ",
+	'j': "ĵј",
+	'k': "ķкκ",
+	'm': "мμ",
+	'n': "ñńņňŉпη",
+	'o': "òóôõöøōŏőǒȍȏоοσ0",
+	'p': "рρ",
+	'r': "ŕŗřг",
+	's': "śŝşšѕ5$",
+	't': "ţťŧтτ7+",
+	'u': "ùúûüũūŭůűųǔυ",
+	'w': "ŵѡω",
+	'x': "хχ",
+	'y': "ýÿŷуγ",
+	'z': "źżžƶ",
+})
+
+func buildFoldMap(groups map[rune]string) map[rune]rune {
+	m := map[rune]rune{}
+	for base, variants := range groups {
+		for _, r := range variants {
+			m[r] = base
+		}
+	}
+	return m
+}
+
+// Fold returns word in the form the filter keys its rules by, so rules
+// stored elsewhere can be compared with the ones a Filter holds.
+func Fold(word string) string {
+	return fold(strings.TrimSpace(word))
+}
+
+// fold reduces a word to the form rules are matched in. Combining marks and
+// invisible format characters such as zero-width spaces are dropped.
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsMark(r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if base, ok := foldMap[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,297 @@
+// Package moderation screens chirp text against a list of blocked words.
+//
+// Words are matched whole, after folding case, common diacritics, look-alike
+// letters from other scripts and leetspeak substitutions, so "Kerfuffle!",
+// "kérfuffle" and "k3rfuuuffl3" all match "kerfuffle" while "kerfuffles" and
+// words that merely contain a blocked word do not.
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Mask is what masked words are replaced with.
+const Mask = "****"
+
+type Action string
+
+// Actions are ordered by severity: a chirp that matches several rules is
+// handled according to the most severe one.
+const (
+	ActionMask   Action = "mask"
+	ActionFlag   Action = "flag"
+	ActionReject Action = "reject"
+)
+
+func (a Action) severity() int {
+	switch a {
+	case ActionMask:
+		return 1
+	case ActionFlag:
+		return 2
+	case ActionReject:
+		return 3
+	}
+	return 0
+}
+
+func ParseAction(s string) (Action, error) {
+	a := Action(strings.ToLower(strings.TrimSpace(s)))
+	if a.severity() == 0 {
+		return "", fmt.Errorf("unknown moderation action %q", s)
+	}
+	return a, nil
+}
+
+type Rule struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+// DefaultRules is the word list used when no configuration is given.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionMask},
+	}
+}
+
+// LoadRules reads a JSON array of rules from path.
+func LoadRules(path string) ([]Rule, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := []Rule{}
+	if err := json.Unmarshal(dat, &rules); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Match is a blocked word found in a text. Start and End are code point
+// offsets into the original text, End exclusive.
+type Match struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+type Result struct {
+	// Text is the input with every masked word replaced by Mask. Words
+	// with a flag or reject action are left as written.
+	Text    string
+	Matches []Match
+}
+
+// Action returns the most severe action among the matches, or "" when
+// nothing matched.
+func (r Result) Action() Action {
+	var worst Action
+	for _, m := range r.Matches {
+		if m.Action.severity() > worst.severity() {
+			worst = m.Action
+		}
+	}
+	return worst
+}
+
+// Words returns the distinct rule words that matched with action a.
+func (r Result) Words(a Action) []string {
+	words := []string{}
+	seen := map[string]bool{}
+	for _, m := range r.Matches {
+		if m.Action == a && !seen[m.Word] {
+			seen[m.Word] = true
+			words = append(words, m.Word)
+		}
+	}
+	return words
+}
+
+type rule struct {
+	word   string
+	runs   []run
+	action Action
+}
+
+// Filter is immutable once built and safe for concurrent use.
+type Filter struct {
+	// rules are keyed by the folded word with repeated letters squeezed,
+	// so "kerfuuuffle" finds the "kerfuffle" rule with one lookup.
+	rules map[string][]rule
+}
+
+// NewFilter builds a filter from rules. Later rules for the same word
+// replace earlier ones, so database rules can override configured ones.
+func NewFilter(rules []Rule) (*Filter, error) {
+	byWord := map[string]rule{}
+	order := []string{}
+	for _, r := range rules {
+		action, err := ParseAction(string(r.Action))
+		if err != nil {
+			return nil, err
+		}
+		word := strings.TrimSpace(r.Word)
+		folded := fold(word)
+		if folded == "" || len(tokenize(word)) != 1 {
+			return nil, fmt.Errorf("moderation word %q must be a single word", r.Word)
+		}
+		if _, ok := byWord[folded]; !ok {
+			order = append(order, folded)
+		}
+		byWord[folded] = rule{word: strings.ToLower(word), runs: runLengths(folded), action: action}
+	}
+	f := &Filter{rules: map[string][]rule{}}
+	for _, folded := range order {
+		r := byWord[folded]
+		key := squeeze(r.runs)
+		f.rules[key] = append(f.rules[key], r)
+	}
+	return f, nil
+}
+
+// Check finds the blocked words in text and masks those whose rule says so.
+func (f *Filter) Check(text string) Result {
+	runes := []rune(text)
+	result := Result{Matches: []Match{}}
+	masked := make([]bool, len(runes))
+	for _, tok := range tokenize(text) {
+		r, start, end, ok := f.match(runes, tok)
+		if !ok {
+			continue
+		}
+		result.Matches = append(result.Matches, Match{Word: r.word, Action: r.action, Start: start, End: end})
+		if r.action == ActionMask {
+			for i := start; i < end; i++ {
+				masked[i] = true
+			}
+		}
+	}
+	var b strings.Builder
+	for i, r := range runes {
+		if !masked[i] {
+			b.WriteRune(r)
+		} else if i == 0 || !masked[i-1] {
+			b.WriteString(Mask)
+		}
+	}
+	result.Text = b.String()
+	return result
+}
+
+// match tries the whole token first and then the token without leading
+// and trailing symbols, so "@ss" can be leetspeak while "kerfuffle!" is
+// still a word followed by punctuation.
+func (f *Filter) match(runes []rune, tok span) (rule, int, int, bool) {
+	candidates := []span{tok}
+	if inner := trimSymbols(runes, tok); inner != tok && inner.start < inner.end {
+		candidates = append(candidates, inner)
+	}
+	for _, c := range candidates {
+		word := string(runes[c.start:c.end])
+		if !hasLetter(word) {
+			// Numbers like "455" aren't leetspeak for anything.
+			continue
+		}
+		runs := runLengths(fold(word))
+		for _, r := range f.rules[squeeze(runs)] {
+			if covers(runs, r.runs) {
+				return r, c.start, c.end, true
+			}
+		}
+	}
+	return rule{}, 0, 0, false
+}
+
+type span struct{ start, end int }
+
+// leetSymbols are the punctuation characters that commonly stand in for
+// letters; they count as part of a word while tokenizing.
+const leetSymbols = "@$!|+"
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) ||
+		unicode.Is(unicode.Cf, r) || strings.ContainsRune(leetSymbols, r)
+}
+
+func tokenize(text string) []span {
+	spans := []span{}
+	start := -1
+	i := 0
+	for _, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+		i++
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, i})
+	}
+	return spans
+}
+
+func trimSymbols(runes []rune, s span) span {
+	for s.start < s.end && !unicode.IsLetter(runes[s.start]) && !unicode.IsDigit(runes[s.start]) {
+		s.start++
+	}
+	for s.end > s.start && !unicode.IsLetter(runes[s.end-1]) && !unicode.IsDigit(runes[s.end-1]) && !unicode.IsMark(runes[s.end-1]) {
+		s.end--
+	}
+	return s
+}
+
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+type run struct {
+	r rune
+	n int
+}
+
+func runLengths(s string) []run {
+	runs := []run{}
+	for _, r := range s {
+		if len(runs) > 0 && runs[len(runs)-1].r == r {
+			runs[len(runs)-1].n++
+		} else {
+			runs = append(runs, run{r, 1})
+		}
+	}
+	return runs
+}
+
+func squeeze(runs []run) string {
+	var b strings.Builder
+	for _, r := range runs {
+		b.WriteRune(r.r)
+	}
+	return b.String()
+}
+
+// covers reports whether got spells want with letters possibly repeated,
+// but never dropped: "kerfuuuffle" covers "kerfuffle", "as" doesn't cover
+// "ass".
+func covers(got, want []run) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].r != want[i].r || got[i].n < want[i].n {
+			return false
+		}
+	}
+	return true
+}
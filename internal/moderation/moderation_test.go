@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	f, err := NewFilter(append(DefaultRules(),
+		Rule{Word: "ass", Action: ActionReject},
+		Rule{Word: "heck", Action: ActionFlag},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		action Action
+	}{
+		{"punctuation", "What a Kerfuffle! kerfuffle.", "What a ****! ****.", ActionMask},
+		{"diacritics and homoglyphs", "kérfuffle ѕharbert", "**** ****", ActionMask},
+		{"leetspeak", "k3rfuuuffl3 f0rn4x", "**** ****", ActionMask},
+		{"zero width characters", "ker\u200bfuffle", "****", ActionMask},
+		{"whole words only", "kerfuffles sharberts", "kerfuffles sharberts", ""},
+		{"repeats never drop letters", "as far as I know", "as far as I know", ""},
+		{"numbers are not leetspeak", "call 455 now", "call 455 now", ""},
+		{"leading symbol", "what an @ss", "what an @ss", ActionReject},
+		{"most severe wins", "heck, a kerfuffle", "heck, a ****", ActionFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Check(tt.text)
+			if got.Text != tt.want {
+				t.Errorf("Text = %q, want %q", got.Text, tt.want)
+			}
+			if got.Action() != tt.action {
+				t.Errorf("Action() = %q, want %q", got.Action(), tt.action)
+			}
+		})
+	}
+}
+
+func TestMatchOffsets(t *testing.T) {
+	f, _ := NewFilter(DefaultRules())
+	got := f.Check("héllo (fornax)").Matches
+	want := []Match{{Word: "fornax", Action: ActionMask, Start: 7, End: 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Matches = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	t.Run("later rules override", func(t *testing.T) {
+		f, err := NewFilter([]Rule{{"fornax", ActionMask}, {"Fornax", ActionReject}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Check("fornax").Action(); got != ActionReject {
+			t.Errorf("Action() = %q, want reject", got)
+		}
+	})
+
+	t.Run("rejects phrases and unknown actions", func(t *testing.T) {
+		if _, err := NewFilter([]Rule{{"two words", ActionMask}}); err == nil {
+			t.Error("expected error for a phrase")
+		}
+		if _, err := NewFilter([]Rule{{"word", "delete"}}); err == nil {
+			t.Error("expected error for an unknown action")
+		}
+	})
+}
+
+func TestFold(t *testing.T) {
+	f, _ := NewFilter([]Rule{{"Kérfuffle", ActionFlag}})
+	if got := f.Check(Fold(" KERFUFFLE ")).Action(); got != ActionFlag {
+		t.Errorf("Action() = %q, want flag", got)
+	}
+	if Fold("Kérfuffle") != Fold("kerfuffle") {
+		t.Errorf("Fold(%q) = %q, want %q", "Kérfuffle", Fold("Kérfuffle"), Fold("kerfuffle"))
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.json")
+	if err := os.WriteFile(path, []byte(`[{"word": "gosh", "action": "flag"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rules, []Rule{{"gosh", ActionFlag}}) {
+		t.Errorf("rules = %+v", rules)
+	}
+}
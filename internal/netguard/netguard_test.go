@@ -0,0 +1,62 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"64:ff9b::a00:1":   false,
+	}
+	for addr, want := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for host, wantErr := range map[string]bool{
+		"8.8.8.8":         false,
+		"169.254.169.254": true,
+		"10.0.0.5":        true,
+		"::1":             true,
+		"localhost":       true,
+	} {
+		err := CheckHost(context.Background(), host)
+		if (err != nil) != wantErr {
+			t.Errorf("CheckHost(%s) = %v, want error %v", host, err, wantErr)
+		}
+		if wantErr && err != nil && !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("CheckHost(%s) = %v, want ErrBlockedAddress", host, err)
+		}
+	}
+}
+
+func TestGuardedTransportRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport()}
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Get(%s) = %v, want ErrBlockedAddress", srv.URL, err)
+	}
+}
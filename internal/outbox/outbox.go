@@ -0,0 +1,183 @@
+// Package outbox implements the transactional outbox pattern. Domain writes
+// call Write with a transaction-scoped *database.Queries so the event is
+// committed atomically with the change, and a Relay later publishes pending
+// events to one or more Sinks.
+//
+// Publishing is at-least-once: a crash between publishing to a sink and
+// recording the delivery re-sends the event. Every event carries its outbox
+// ID, which sinks pass along as an idempotency key so consumers can drop
+// duplicates, making delivery effectively exactly-once.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func EventFromDB(e database.OutboxEvent) Event {
+	return Event{
+		ID:            e.ID,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		Type:          e.EventType,
+		Payload:       e.Payload,
+		CreatedAt:     e.CreatedAt,
+	}
+}
+
+// Write appends an event to the outbox. q should be bound to the same
+// transaction as the domain write it describes.
+func Write(ctx context.Context, q *database.Queries, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) (Event, error) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	e, err := q.InsertOutboxEvent(ctx, database.InsertOutboxEventParams{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       dat,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return EventFromDB(e), nil
+}
+
+// Sink receives published events. Name must be stable across restarts
+// because it is recorded per event to avoid re-publishing to sinks that
+// already succeeded.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, e Event) error
+}
+
+// ErrNoSinks is returned by RelayOnce when there is nowhere to publish to.
+// Events stay pending rather than being marked published.
+var ErrNoSinks = errors.New("outbox relay has no sinks")
+
+// Relay publishes pending events. Claiming a batch leases it for Lease so
+// that no transaction is held open while sinks are called; if the relay
+// dies mid-batch, the events become due again once the lease runs out.
+type Relay struct {
+	queries      *database.Queries
+	sinks        []Sink
+	BatchSize    int32
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxBackoff   time.Duration
+}
+
+func NewRelay(queries *database.Queries, sinks []Sink) *Relay {
+	return &Relay{
+		queries:      queries,
+		sinks:        sinks,
+		BatchSize:    50,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		MaxBackoff:   5 * time.Minute,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil {
+			log.Printf("outbox relay error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and reports how many were
+// fully published.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	if len(r.sinks) == 0 {
+		return 0, ErrNoSinks
+	}
+	leaseEnd := time.Now().Add(r.Lease)
+	events, err := r.queries.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+		LeaseSeconds: r.Lease.Seconds(),
+		Limit:        r.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, row := range events {
+		// Past the lease another relay may already have the rest of the
+		// batch; leave it to them.
+		if time.Now().After(leaseEnd) {
+			break
+		}
+		ok, err := r.publish(ctx, row)
+		if err != nil {
+			return published, err
+		}
+		if ok {
+			published++
+		}
+	}
+	return published, nil
+}
+
+func (r *Relay) publish(ctx context.Context, row database.OutboxEvent) (bool, error) {
+	done, err := r.queries.ListOutboxSinkDeliveries(ctx, row.ID)
+	if err != nil {
+		return false, err
+	}
+	delivered := map[string]bool{}
+	for _, name := range done {
+		delivered[name] = true
+	}
+
+	event := EventFromDB(row)
+	var errs []error
+	for _, sink := range r.sinks {
+		if delivered[sink.Name()] {
+			continue
+		}
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		err := r.queries.RecordOutboxSinkDelivery(ctx, database.RecordOutboxSinkDeliveryParams{
+			EventID: row.ID,
+			Sink:    sink.Name(),
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(errs) > 0 {
+		backoff := min(time.Duration(row.Attempts+1)*time.Duration(row.Attempts+1)*time.Second, r.MaxBackoff)
+		return false, r.queries.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{
+			LastError:      errors.Join(errs...).Error(),
+			BackoffSeconds: backoff.Seconds(),
+			ID:             row.ID,
+		})
+	}
+	return true, r.queries.MarkOutboxEventPublished(ctx, row.ID)
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+var outboxEventColumns = []string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "created_at", "published_at", "attempts", "next_attempt_at", "last_error", "txid"}
+
+// recordingSink fails while err is set and otherwise remembers what it was
+// given.
+type recordingSink struct {
+	err    error
+	events []Event
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Publish(ctx context.Context, e Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func newRelayTest(t *testing.T, sinks ...Sink) (*Relay, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewRelay(database.New(db), sinks), mock
+}
+
+func pendingEventRows(ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows(outboxEventColumns)
+	for _, id := range ids {
+		now := time.Now()
+		rows.AddRow(id, "chirp", uuid.New(), "chirp.created", []byte(`{}`), now, nil, 0, now, "", 900)
+	}
+	return rows
+}
+
+func TestRelayPublishesOutsideTransaction(t *testing.T) {
+	sink := &recordingSink{}
+	relay, mock := newRelayTest(t, sink)
+	// No Begin is expected: the claim commits on its own before any sink
+	// is called.
+	mock.ExpectQuery("UPDATE outbox_events\\s+SET next_attempt_at").
+		WithArgs(relay.Lease.Seconds(), relay.BatchSize).
+		WillReturnRows(pendingEventRows(7))
+	mock.ExpectQuery("SELECT sink FROM outbox_sink_deliveries").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"sink"}))
+	mock.ExpectExec("INSERT INTO outbox_sink_deliveries").
+		WithArgs(int64(7), "recording").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox_events\\s+SET published_at").
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("relay failed: %v", err)
+	}
+	if n != 1 || len(sink.events) != 1 || sink.events[0].ID != 7 {
+		t.Errorf("published %d, sink got %v; want event 7", n, sink.events)
+	}
+}
+
+func TestRelaySkipsSinksAlreadyDelivered(t *testing.T) {
+	sink := &recordingSink{}
+	relay, mock := newRelayTest(t, sink)
+	mock.ExpectQuery("UPDATE outbox_events\\s+SET next_attempt_at").
+		WillReturnRows(pendingEventRows(7))
+	mock.ExpectQuery("SELECT sink FROM outbox_sink_deliveries").
+		WillReturnRows(sqlmock.NewRows([]string{"sink"}).AddRow("recording"))
+	mock.ExpectExec("UPDATE outbox_events\\s+SET published_at").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatalf("relay failed: %v", err)
+	}
+	if len(sink.events) != 0 {
+		t.Errorf("sink got %d events, want none", len(sink.events))
+	}
+}
+
+func TestRelayBacksOffFailedEvents(t *testing.T) {
+	sink := &recordingSink{err: errors.New("unavailable")}
+	relay, mock := newRelayTest(t, sink)
+	mock.ExpectQuery("UPDATE outbox_events\\s+SET next_attempt_at").
+		WillReturnRows(pendingEventRows(7))
+	mock.ExpectQuery("SELECT sink FROM outbox_sink_deliveries").
+		WillReturnRows(sqlmock.NewRows([]string{"sink"}))
+	mock.ExpectExec("UPDATE outbox_events\\s+SET attempts").
+		WithArgs("recording: unavailable", float64(1), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("relay failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("published %d, want 0", n)
+	}
+}
+
+func TestRelayWithoutSinksLeavesEventsPending(t *testing.T) {
+	relay, _ := newRelayTest(t)
+	if _, err := relay.RelayOnce(context.Background()); !errors.Is(err, ErrNoSinks) {
+		t.Errorf("err = %v, want %v", err, ErrNoSinks)
+	}
+}
@@ -0,0 +1,236 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseSinks builds sinks from a comma separated spec such as
+// "stdout,file:/var/log/chirpy/events.jsonl,webhook:https://example.com/hook,nats:localhost:4222".
+func ParseSinks(spec string) ([]Sink, error) {
+	sinks := []Sink{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, arg, _ := strings.Cut(part, ":")
+		switch kind {
+		case "stdout":
+			sinks = append(sinks, NewWriterSink("stdout", os.Stdout))
+		case "file":
+			if arg == "" {
+				return nil, fmt.Errorf("file sink needs a path")
+			}
+			sinks = append(sinks, NewFileSink(arg))
+		case "webhook":
+			if arg == "" {
+				return nil, fmt.Errorf("webhook sink needs a URL")
+			}
+			sinks = append(sinks, NewHTTPSink(arg, &http.Client{Timeout: 10 * time.Second}))
+		case "nats":
+			if arg == "" {
+				return nil, fmt.Errorf("nats sink needs an address")
+			}
+			sinks = append(sinks, NewNATSSink(arg, "chirpy.events"))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", kind)
+		}
+	}
+	return sinks, nil
+}
+
+// WriterSink writes each event as a JSON line.
+type WriterSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+func (s *WriterSink) Name() string { return s.name }
+
+func (s *WriterSink) Publish(ctx context.Context, e Event) error {
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(dat, '\n'))
+	return err
+}
+
+// FileSink appends JSON lines to a file and syncs after every event so a
+// recorded delivery is never lost on crash.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string { return "file:" + s.path }
+
+func (s *FileSink) Publish(ctx context.Context, e Event) error {
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(dat, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HTTPSink POSTs each event as JSON with an Idempotency-Key header.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	return &HTTPSink{url: url, client: client}
+}
+
+func (s *HTTPSink) Name() string { return "webhook:" + s.url }
+
+func (s *HTTPSink) Publish(ctx context.Context, e Event) error {
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(dat))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(e.ID, 10))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NATSSink speaks enough of the NATS client protocol to publish events with
+// a Nats-Msg-Id header, which JetStream uses for duplicate detection. Each
+// publish is followed by PING and waits for PONG, so an error from the
+// server is seen before the delivery is recorded.
+type NATSSink struct {
+	addr       string
+	subjectPfx string
+	timeout    time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewNATSSink(addr, subjectPrefix string) *NATSSink {
+	return &NATSSink{addr: addr, subjectPfx: subjectPrefix, timeout: 5 * time.Second}
+}
+
+func (s *NATSSink) Name() string { return "nats:" + s.addr }
+
+func (s *NATSSink) Publish(ctx context.Context, e Event) error {
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.hpub(e, dat); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *NATSSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(conn, `CONNECT {"verbose":false,"pedantic":false,"headers":true,"name":"chirpy-outbox"}`+"\r\n"); err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn, s.reader = conn, reader
+	return nil
+}
+
+func (s *NATSSink) hpub(e Event, payload []byte) error {
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + strconv.FormatInt(e.ID, 10) + "\r\n\r\n"
+	subject := s.subjectPfx + "." + e.Type
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	msg := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n", subject, len(headers), len(headers)+len(payload), headers, payload)
+	if _, err := io.WriteString(s.conn, msg); err != nil {
+		return err
+	}
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			io.WriteString(s.conn, "PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		}
+	}
+}
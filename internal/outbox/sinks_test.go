@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testEvent() Event {
+	return Event{
+		ID:            42,
+		AggregateType: "chirp",
+		AggregateID:   uuid.New(),
+		Type:          "chirp.created",
+		Payload:       json.RawMessage(`{"body":"hello"}`),
+		CreatedAt:     time.Unix(1_700_000_000, 0).UTC(),
+	}
+}
+
+func TestParseSinks(t *testing.T) {
+	sinks, err := ParseSinks("stdout, file:/tmp/events.jsonl,webhook:http://localhost:9000/hook,nats:localhost:4222")
+	if err != nil {
+		t.Fatalf("failed to parse sinks: %v", err)
+	}
+	names := []string{}
+	for _, s := range sinks {
+		names = append(names, s.Name())
+	}
+	want := "stdout,file:/tmp/events.jsonl,webhook:http://localhost:9000/hook,nats:localhost:4222"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("sink names = %q, want %q", got, want)
+	}
+	if _, err := ParseSinks("kafka:localhost"); err == nil {
+		t.Error("expected error for unknown sink, but got none")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := NewFileSink(path)
+	for i := 0; i < 2; i++ {
+		if err := sink.Publish(context.Background(), testEvent()); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(dat)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 42 || got.Type != "chirp.created" {
+		t.Errorf("unexpected event %+v", got)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("Idempotency-Key")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, server.Client())
+	if err := sink.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if key != "42" {
+		t.Errorf("Idempotency-Key = %q, want 42", key)
+	}
+}
+
+func TestNATSSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "INFO {\"headers\":true}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "HPUB ") {
+				var subject string
+				var hdrLen, totalLen int
+				fmt.Sscanf(line, "HPUB %s %d %d", &subject, &hdrLen, &totalLen)
+				msg := make([]byte, totalLen+2)
+				io.ReadFull(r, msg)
+				received <- subject + "\n" + string(msg[:hdrLen])
+			}
+			if strings.TrimSpace(line) == "PING" {
+				io.WriteString(conn, "PONG\r\n")
+			}
+		}
+	}()
+
+	sink := NewNATSSink(ln.Addr().String(), "chirpy.events")
+	if err := sink.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	got := <-received
+	if !strings.HasPrefix(got, "chirpy.events.chirp.created\n") {
+		t.Errorf("unexpected subject in %q", got)
+	}
+	if !strings.Contains(got, "Nats-Msg-Id: 42") {
+		t.Errorf("expected Nats-Msg-Id header, got %q", got)
+	}
+}
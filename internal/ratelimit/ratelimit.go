@@ -0,0 +1,243 @@
+// Package ratelimit implements token-bucket rate limiting. Policies are
+// grouped by route and keyed by who is calling, and buckets live in a
+// Store so a Postgres-backed one can share limits across instances.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Identity names what a policy counts requests by.
+type Identity string
+
+const (
+	IdentityIP     Identity = "ip"
+	IdentityUser   Identity = "user"
+	IdentityAPIKey Identity = "api_key"
+)
+
+// Policy allows Limit requests per Period, refilling continuously, so a
+// caller can burst up to Limit and then sustain Limit/Period.
+type Policy struct {
+	Identity Identity
+	Limit    int
+	Period   time.Duration
+}
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Identity Identity `json:"identity"`
+		Limit    int      `json:"limit"`
+		Period   string   `json:"period"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	period, err := time.ParseDuration(raw.Period)
+	if err != nil {
+		return fmt.Errorf("period: %w", err)
+	}
+	*p = Policy{Identity: raw.Identity, Limit: raw.Limit, Period: period}
+	return nil
+}
+
+func (p Policy) validate() error {
+	switch p.Identity {
+	case IdentityIP, IdentityUser, IdentityAPIKey:
+	default:
+		return fmt.Errorf("unknown identity %q", p.Identity)
+	}
+	if p.Limit <= 0 {
+		return fmt.Errorf("limit must be positive")
+	}
+	if p.Period <= 0 {
+		return fmt.Errorf("period must be positive")
+	}
+	return nil
+}
+
+// Config maps a route name to the policies that apply to it. A request
+// must satisfy every policy whose identity it has.
+type Config map[string][]Policy
+
+func DefaultConfig() Config {
+	return Config{
+		"login": {
+			{Identity: IdentityIP, Limit: 10, Period: time.Minute},
+		},
+		"refresh": {
+			{Identity: IdentityIP, Limit: 30, Period: time.Minute},
+		},
+		"signup": {
+			{Identity: IdentityIP, Limit: 5, Period: time.Hour},
+		},
+		"chirps.create": {
+			{Identity: IdentityUser, Limit: 30, Period: time.Minute},
+			{Identity: IdentityUser, Limit: 300, Period: 24 * time.Hour},
+			{Identity: IdentityIP, Limit: 60, Period: time.Minute},
+		},
+		"media.upload": {
+			{Identity: IdentityUser, Limit: 20, Period: time.Minute},
+		},
+		"reports.create": {
+			{Identity: IdentityUser, Limit: 20, Period: time.Hour},
+		},
+		// Signed Polka deliveries carry no API key, so the IP policy is
+		// the only one that applies to them.
+		"webhooks": {
+			{Identity: IdentityAPIKey, Limit: 120, Period: time.Minute},
+			{Identity: IdentityIP, Limit: 120, Period: time.Minute},
+		},
+	}
+}
+
+// LoadConfig reads a JSON object keyed by route name, for example
+// {"login": [{"identity": "ip", "limit": 5, "period": "1m"}]}. Routes in
+// the file replace the defaults for that route; an empty list turns
+// limiting off for it.
+func LoadConfig(path string) (Config, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	routes := Config{}
+	if err := json.Unmarshal(dat, &routes); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	config := DefaultConfig()
+	for route, policies := range routes {
+		for i, p := range policies {
+			if err := p.validate(); err != nil {
+				return nil, fmt.Errorf("route %q policy %d: %w", route, i, err)
+			}
+		}
+		config[route] = policies
+	}
+	return config, nil
+}
+
+// Store holds token buckets by key.
+type Store interface {
+	// Take refills key's bucket at rate tokens per second, capped at
+	// burst, then takes one token if there is one. It returns the tokens
+	// left and whether one was taken.
+	Take(ctx context.Context, key string, rate, burst float64) (tokens float64, ok bool, err error)
+}
+
+// Identities are who a request came from. Empty fields are unknown, and
+// policies counting by them are skipped. Multiplier scales the limits of
+// user and API key policies, so paid plans get more headroom; zero means 1.
+type Identities struct {
+	IP         string
+	UserID     string
+	APIKey     string
+	Multiplier float64
+}
+
+func (ids Identities) get(identity Identity) string {
+	switch identity {
+	case IdentityIP:
+		return ids.IP
+	case IdentityUser:
+		return ids.UserID
+	case IdentityAPIKey:
+		return ids.APIKey
+	}
+	return ""
+}
+
+// Decision is the outcome of the most restrictive policy a request was
+// checked against.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Period     time.Duration
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// SetHeaders writes the RateLimit-* headers, and Retry-After when the
+// request was refused. Durations are rounded up to whole seconds.
+func (d *Decision) SetHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", d.Limit, seconds(d.Period)))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(d.RetryAfter)))
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type Limiter struct {
+	store  Store
+	config Config
+}
+
+func NewLimiter(store Store, config Config) *Limiter {
+	return &Limiter{store: store, config: config}
+}
+
+// Allow takes a token from every bucket of route that applies to ids. It
+// returns nil when no policy applies. Buckets are drawn from even after
+// one refuses, so a caller that keeps retrying stays limited everywhere.
+func (l *Limiter) Allow(ctx context.Context, route string, ids Identities) (*Decision, error) {
+	var decision *Decision
+	for i, p := range l.config[route] {
+		who := ids.get(p.Identity)
+		if who == "" {
+			continue
+		}
+		limit := p.Limit
+		if p.Identity != IdentityIP && ids.Multiplier > 0 {
+			limit = max(1, int(math.Round(float64(p.Limit)*ids.Multiplier)))
+		}
+		rate := float64(limit) / p.Period.Seconds()
+		key := fmt.Sprintf("%s:%d:%s:%s", route, i, p.Identity, who)
+		tokens, ok, err := l.store.Take(ctx, key, rate, float64(limit))
+		if err != nil {
+			return nil, err
+		}
+		d := &Decision{
+			Allowed:   ok,
+			Limit:     limit,
+			Remaining: int(math.Floor(tokens)),
+			Period:    p.Period,
+			Reset:     secondsToDuration((float64(limit) - tokens) / rate),
+		}
+		if !ok {
+			d.RetryAfter = secondsToDuration((1 - tokens) / rate)
+		}
+		if decision == nil || moreRestrictive(d, decision) {
+			decision = d
+		}
+	}
+	return decision, nil
+}
+
+// moreRestrictive reports whether a should be reported over b: refusals
+// win, the longest wait among refusals, otherwise the fewest remaining.
+func moreRestrictive(a, b *Decision) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestLimiter(config Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	return NewLimiter(store, config), clock
+}
+
+func TestLimiterRefills(t *testing.T) {
+	limiter, clock := newTestLimiter(Config{
+		"login": {{Identity: IdentityIP, Limit: 2, Period: time.Minute}},
+	})
+	ids := Identities{IP: "10.0.0.1"}
+	for i := 0; i < 2; i++ {
+		d, err := limiter.Allow(context.Background(), "login", ids)
+		if err != nil || !d.Allowed {
+			t.Fatalf("request %d refused: %+v, %v", i, d, err)
+		}
+	}
+	d, _ := limiter.Allow(context.Background(), "login", ids)
+	if d.Allowed {
+		t.Fatal("third request allowed")
+	}
+	if d.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %s, want 30s", d.RetryAfter)
+	}
+	if d, _ := limiter.Allow(context.Background(), "login", Identities{IP: "10.0.0.2"}); !d.Allowed {
+		t.Error("other IP refused")
+	}
+
+	clock.t = clock.t.Add(30 * time.Second)
+	if d, _ := limiter.Allow(context.Background(), "login", ids); !d.Allowed {
+		t.Error("request after refill refused")
+	}
+}
+
+func TestLimiterSkipsUnknownIdentities(t *testing.T) {
+	limiter, _ := newTestLimiter(Config{
+		"chirps": {{Identity: IdentityUser, Limit: 1, Period: time.Minute}},
+	})
+	d, err := limiter.Allow(context.Background(), "chirps", Identities{IP: "10.0.0.1"})
+	if err != nil || d != nil {
+		t.Fatalf("Allow = %+v, %v; want no decision", d, err)
+	}
+	if d, _ := limiter.Allow(context.Background(), "other", Identities{UserID: "u"}); d != nil {
+		t.Errorf("unconfigured route got %+v", d)
+	}
+}
+
+func TestDefaultConfigLimitsWebhooksWithoutAPIKey(t *testing.T) {
+	limiter, _ := newTestLimiter(DefaultConfig())
+	d, err := limiter.Allow(context.Background(), "webhooks", Identities{IP: "10.0.0.1"})
+	if err != nil || d == nil {
+		t.Fatalf("Allow = %+v, %v; want a decision", d, err)
+	}
+	if d.Limit != 120 {
+		t.Errorf("Limit = %d, want 120", d.Limit)
+	}
+}
+
+func TestLimiterMultiplier(t *testing.T) {
+	limiter, _ := newTestLimiter(Config{
+		"chirps": {
+			{Identity: IdentityUser, Limit: 2, Period: time.Minute},
+			{Identity: IdentityIP, Limit: 100, Period: time.Minute},
+		},
+	})
+	ids := Identities{IP: "10.0.0.1", UserID: "u", Multiplier: 3}
+	for i := 0; i < 6; i++ {
+		d, _ := limiter.Allow(context.Background(), "chirps", ids)
+		if !d.Allowed {
+			t.Fatalf("request %d refused", i)
+		}
+		if d.Limit != 6 {
+			t.Fatalf("reported the limit %d, want the tighter user limit 6", d.Limit)
+		}
+	}
+	if d, _ := limiter.Allow(context.Background(), "chirps", ids); d.Allowed {
+		t.Error("request past the scaled limit allowed")
+	}
+}
+
+func TestDecisionHeaders(t *testing.T) {
+	d := &Decision{Limit: 10, Remaining: 0, Period: time.Minute, Reset: 59500 * time.Millisecond, RetryAfter: 5500 * time.Millisecond}
+	h := http.Header{}
+	d.SetHeaders(h)
+	want := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    "10;w=60",
+		"Retry-After":         "6",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.json")
+	os.WriteFile(path, []byte(`{"login": [{"identity": "ip", "limit": 3, "period": "10s"}], "signup": []}`), 0o600)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config["login"]; len(got) != 1 || got[0].Limit != 3 || got[0].Period != 10*time.Second {
+		t.Errorf("login = %+v", got)
+	}
+	if len(config["signup"]) != 0 {
+		t.Errorf("signup = %+v, want limiting off", config["signup"])
+	}
+	if len(config["refresh"]) == 0 {
+		t.Error("refresh default dropped")
+	}
+
+	os.WriteFile(path, []byte(`{"login": [{"identity": "cookie", "limit": 3, "period": "10s"}]}`), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("unknown identity accepted")
+	}
+}
+
+func TestPostgresStoreSweepsInSQLTime(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// The cutoff is computed by the database, so only the idle period is
+	// sent; a failed sweep doesn't fail the request.
+	mock.ExpectExec("DELETE FROM rate_limit_buckets").WithArgs(float64(3600)).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("INSERT INTO rate_limit_buckets").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "allowed"}).AddRow(4.0, true))
+
+	store := NewPostgresStore(database.New(db), time.Hour)
+	if _, allowed, err := store.Take(context.Background(), "ip:1", 1, 5); err != nil || !allowed {
+		t.Fatalf("Take() = %v, %v, want allowed", allowed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
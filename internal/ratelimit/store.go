@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+// sweepInterval is how often stores forget buckets that have refilled.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process. Limits are per instance, so it
+// suits single-instance deployments and tests.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	burst   float64
+	rate    float64
+	updated time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rate, burst float64) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		s.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+	b.tokens = math.Min(burst, b.tokens+rate*now.Sub(b.updated).Seconds())
+	b.updated = now
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+// sweep drops buckets that would be full by now; a fresh bucket is the
+// same thing.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.tokens+b.rate*now.Sub(b.updated).Seconds() >= b.burst {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// instance enforces the same limits.
+type PostgresStore struct {
+	db *database.Queries
+	// idle is how long a bucket may go untouched before it's deleted. It
+	// must be at least the longest policy period.
+	idle time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *database.Queries, idle time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, idle: idle}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, rate, burst float64) (float64, bool, error) {
+	s.maybeSweep(ctx)
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: burst,
+		Rate:  rate,
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return row.Tokens, row.Allowed, nil
+}
+
+// maybeSweep deletes idle buckets at most once per sweepInterval per
+// instance. Failures only delay the cleanup, so they are logged rather
+// than failing the request.
+func (s *PostgresStore) maybeSweep(ctx context.Context) {
+	s.mu.Lock()
+	now := time.Now().UTC()
+	due := now.Sub(s.lastSweep) >= sweepInterval
+	if due {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+	if due {
+		if _, err := s.db.DeleteIdleRateLimitBuckets(ctx, s.idle.Seconds()); err != nil {
+			log.Printf("error sweeping idle rate limit buckets: %s", err)
+		}
+	}
+}
+
+// MaxPeriod returns the longest period in config, which is how long a
+// PostgresStore has to keep idle buckets.
+func MaxPeriod(config Config) time.Duration {
+	var longest time.Duration
+	for _, policies := range config {
+		for _, p := range policies {
+			longest = max(longest, p.Period)
+		}
+	}
+	return longest
+}
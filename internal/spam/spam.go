@@ -0,0 +1,230 @@
+// Package spam scores new chirps against a handful of heuristics: posting
+// the same body over and over, bodies that are mostly links, new accounts
+// posting in bursts and mention bombing. Each heuristic adds points and
+// the total picks a verdict from configurable thresholds.
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/entities"
+)
+
+// Verdict is what to do with a chirp, from least to most severe.
+type Verdict string
+
+const (
+	// Allow publishes the chirp as usual.
+	Allow Verdict = "allow"
+	// Review holds the chirp back until a moderator looks at it.
+	Review Verdict = "review"
+	// Shadow publishes the chirp to its author only, without telling them.
+	Shadow Verdict = "shadow"
+	// Reject refuses the chirp.
+	Reject Verdict = "reject"
+)
+
+type Config struct {
+	// DuplicateWindow is how far back an identical body from the same
+	// author counts; DuplicatePoints are added per earlier copy.
+	DuplicateWindow time.Duration
+	DuplicatePoints float64
+	// A chirp with at least two links and more than MaxLinkDensity links
+	// per word scores LinkPoints.
+	MaxLinkDensity float64
+	LinkPoints     float64
+	// Accounts younger than NewAccountAge may post NewAccountHourlyLimit
+	// chirps an hour; each chirp past that scores VelocityPoints.
+	NewAccountAge         time.Duration
+	NewAccountHourlyLimit int
+	VelocityPoints        float64
+	// Each distinct mention past MaxMentions scores MentionPoints.
+	MaxMentions   int
+	MentionPoints float64
+	// Scores at or above a threshold get its verdict, the most severe
+	// winning. A zero threshold is never reached.
+	ReviewAt float64
+	ShadowAt float64
+	RejectAt float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DuplicateWindow:       24 * time.Hour,
+		DuplicatePoints:       2,
+		MaxLinkDensity:        0.5,
+		LinkPoints:            2,
+		NewAccountAge:         24 * time.Hour,
+		NewAccountHourlyLimit: 5,
+		VelocityPoints:        1,
+		MaxMentions:           5,
+		MentionPoints:         0.5,
+		ReviewAt:              3,
+		ShadowAt:              5,
+		RejectAt:              8,
+	}
+}
+
+// LoadConfig reads a JSON object with snake_case keys, for example
+// {"duplicate_window": "12h", "reject_at": 10}. Keys missing from the file
+// keep their defaults.
+func LoadConfig(path string) (Config, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c := DefaultConfig()
+	raw := struct {
+		DuplicateWindow       *string  `json:"duplicate_window"`
+		DuplicatePoints       *float64 `json:"duplicate_points"`
+		MaxLinkDensity        *float64 `json:"max_link_density"`
+		LinkPoints            *float64 `json:"link_points"`
+		NewAccountAge         *string  `json:"new_account_age"`
+		NewAccountHourlyLimit *int     `json:"new_account_hourly_limit"`
+		VelocityPoints        *float64 `json:"velocity_points"`
+		MaxMentions           *int     `json:"max_mentions"`
+		MentionPoints         *float64 `json:"mention_points"`
+		ReviewAt              *float64 `json:"review_at"`
+		ShadowAt              *float64 `json:"shadow_at"`
+		RejectAt              *float64 `json:"reject_at"`
+	}{}
+	if err := json.Unmarshal(dat, &raw); err != nil {
+		return Config{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if raw.DuplicateWindow != nil {
+		if c.DuplicateWindow, err = time.ParseDuration(*raw.DuplicateWindow); err != nil {
+			return Config{}, fmt.Errorf("duplicate_window: %w", err)
+		}
+	}
+	if raw.NewAccountAge != nil {
+		if c.NewAccountAge, err = time.ParseDuration(*raw.NewAccountAge); err != nil {
+			return Config{}, fmt.Errorf("new_account_age: %w", err)
+		}
+	}
+	set := func(dst *float64, src *float64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&c.DuplicatePoints, raw.DuplicatePoints)
+	set(&c.MaxLinkDensity, raw.MaxLinkDensity)
+	set(&c.LinkPoints, raw.LinkPoints)
+	set(&c.VelocityPoints, raw.VelocityPoints)
+	set(&c.MentionPoints, raw.MentionPoints)
+	set(&c.ReviewAt, raw.ReviewAt)
+	set(&c.ShadowAt, raw.ShadowAt)
+	set(&c.RejectAt, raw.RejectAt)
+	if raw.NewAccountHourlyLimit != nil {
+		c.NewAccountHourlyLimit = *raw.NewAccountHourlyLimit
+	}
+	if raw.MaxMentions != nil {
+		c.MaxMentions = *raw.MaxMentions
+	}
+	return c, nil
+}
+
+// Recent is one of the author's earlier chirps.
+type Recent struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Input is a chirp about to be created and what is known of its author.
+// Recent should cover at least the last DuplicateWindow and hour.
+type Input struct {
+	Body            string
+	AuthorCreatedAt time.Time
+	Recent          []Recent
+	Now             time.Time
+}
+
+// Signal is one heuristic that fired.
+type Signal struct {
+	Name   string  `json:"name"`
+	Points float64 `json:"points"`
+	Detail string  `json:"detail"`
+}
+
+type Result struct {
+	Score   float64  `json:"score"`
+	Verdict Verdict  `json:"verdict"`
+	Signals []Signal `json:"signals"`
+}
+
+// Summary describes the signals for the moderation queue.
+func (r Result) Summary() string {
+	parts := make([]string, 0, len(r.Signals))
+	for _, s := range r.Signals {
+		parts = append(parts, s.Detail)
+	}
+	return fmt.Sprintf("spam score %.1f: %s", r.Score, strings.Join(parts, "; "))
+}
+
+func (c Config) Score(in Input) Result {
+	result := Result{Verdict: Allow, Signals: []Signal{}}
+	add := func(name string, points float64, detail string) {
+		if points <= 0 {
+			return
+		}
+		result.Score += points
+		result.Signals = append(result.Signals, Signal{Name: name, Points: points, Detail: detail})
+	}
+
+	body := normalize(in.Body)
+	copies, lastHour := 0, 0
+	for _, r := range in.Recent {
+		age := in.Now.Sub(r.CreatedAt)
+		if age <= c.DuplicateWindow && normalize(r.Body) == body {
+			copies++
+		}
+		if age <= time.Hour {
+			lastHour++
+		}
+	}
+	if copies > 0 {
+		add("duplicate", float64(copies)*c.DuplicatePoints,
+			fmt.Sprintf("posted %d times in %s", copies+1, c.DuplicateWindow))
+	}
+
+	ents := entities.Parse(in.Body)
+	links, words := len(ents.URLs), len(strings.Fields(in.Body))
+	if links >= 2 && words > 0 && float64(links)/float64(words) > c.MaxLinkDensity {
+		add("link_density", c.LinkPoints, fmt.Sprintf("%d links in %d words", links, words))
+	}
+
+	if in.Now.Sub(in.AuthorCreatedAt) < c.NewAccountAge {
+		if over := lastHour + 1 - c.NewAccountHourlyLimit; over > 0 {
+			add("new_account_velocity", float64(over)*c.VelocityPoints,
+				fmt.Sprintf("new account posted %d chirps in the last hour", lastHour+1))
+		}
+	}
+
+	if mentions := len(ents.Handles()); mentions > c.MaxMentions {
+		add("mention_bombing", float64(mentions-c.MaxMentions)*c.MentionPoints,
+			fmt.Sprintf("mentions %d users", mentions))
+	}
+
+	switch {
+	case reached(result.Score, c.RejectAt):
+		result.Verdict = Reject
+	case reached(result.Score, c.ShadowAt):
+		result.Verdict = Shadow
+	case reached(result.Score, c.ReviewAt):
+		result.Verdict = Review
+	}
+	return result
+}
+
+func reached(score, threshold float64) bool {
+	return threshold > 0 && score >= threshold
+}
+
+// normalize makes bodies that differ only in case or spacing compare
+// equal.
+func normalize(body string) string {
+	return strings.Join(strings.Fields(strings.ToLower(body)), " ")
+}
@@ -0,0 +1,99 @@
+package spam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func oldAccount() time.Time { return now.Add(-365 * 24 * time.Hour) }
+
+func TestScoreAllowsOrdinaryChirp(t *testing.T) {
+	r := DefaultConfig().Score(Input{
+		Body:            "Read this https://example.com/post, thoughts @alice?",
+		AuthorCreatedAt: oldAccount(),
+		Recent:          []Recent{{Body: "something else", CreatedAt: now.Add(-time.Minute)}},
+		Now:             now,
+	})
+	if r.Verdict != Allow || r.Score != 0 {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestScoreDuplicates(t *testing.T) {
+	c := DefaultConfig()
+	in := Input{Body: "Buy  NOW", AuthorCreatedAt: oldAccount(), Now: now}
+	want := []Verdict{Allow, Allow, Review, Shadow, Reject}
+	for i, v := range want {
+		if r := c.Score(in); r.Verdict != v {
+			t.Errorf("with %d earlier copies got %s (score %.1f), want %s", i, r.Verdict, r.Score, v)
+		}
+		in.Recent = append(in.Recent, Recent{Body: "buy now", CreatedAt: now.Add(-time.Duration(i+2) * time.Hour)})
+	}
+
+	in.Recent = []Recent{{Body: "buy now", CreatedAt: now.Add(-48 * time.Hour)}}
+	if r := c.Score(in); r.Score != 0 {
+		t.Errorf("copy outside the window scored %.1f", r.Score)
+	}
+}
+
+func TestScoreLinkDensity(t *testing.T) {
+	r := DefaultConfig().Score(Input{
+		Body:            "https://a.example https://b.example deals",
+		AuthorCreatedAt: oldAccount(),
+		Now:             now,
+	})
+	if len(r.Signals) != 1 || r.Signals[0].Name != "link_density" {
+		t.Errorf("signals = %+v", r.Signals)
+	}
+}
+
+func TestScoreNewAccountVelocity(t *testing.T) {
+	c := DefaultConfig()
+	in := Input{Body: "hi", AuthorCreatedAt: now.Add(-time.Hour), Now: now}
+	for i := 0; i < 4; i++ {
+		in.Recent = append(in.Recent, Recent{Body: "post " + string(rune('a'+i)), CreatedAt: now.Add(-time.Duration(i+1) * time.Minute)})
+	}
+	if r := c.Score(in); r.Score != 0 {
+		t.Errorf("fifth chirp scored %.1f", r.Score)
+	}
+	in.Recent = append(in.Recent, Recent{Body: "post e", CreatedAt: now.Add(-10 * time.Minute)})
+	if r := c.Score(in); r.Score != c.VelocityPoints {
+		t.Errorf("sixth chirp scored %.1f", r.Score)
+	}
+	in.AuthorCreatedAt = oldAccount()
+	if r := c.Score(in); r.Score != 0 {
+		t.Errorf("established account scored %.1f", r.Score)
+	}
+}
+
+func TestScoreMentionBombing(t *testing.T) {
+	handles := []string{}
+	for _, h := range []string{"aaa", "bbb", "ccc", "ddd", "eee", "fff", "ggg", "hhh", "iii", "jjj", "kkk"} {
+		handles = append(handles, "@"+h)
+	}
+	r := DefaultConfig().Score(Input{Body: strings.Join(handles, " "), AuthorCreatedAt: oldAccount(), Now: now})
+	if r.Score != 3 || r.Verdict != Review {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spam.json")
+	os.WriteFile(path, []byte(`{"duplicate_window": "1h", "reject_at": 0, "max_mentions": 2}`), 0o600)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DuplicateWindow != time.Hour || c.RejectAt != 0 || c.MaxMentions != 2 || c.ShadowAt != DefaultConfig().ShadowAt {
+		t.Errorf("got %+v", c)
+	}
+	os.WriteFile(path, []byte(`{"new_account_age": "soon"}`), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("bad duration accepted")
+	}
+}
@@ -0,0 +1,215 @@
+// Package stream fans chirp events out to live subscribers. Events come from
+// the outbox: every insert into outbox_events fires a Postgres NOTIFY, and
+// each server instance runs a Listener that loads newly committed events
+// and publishes them to its local Hub, so subscribers on any instance see
+// every write.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+const (
+	EventChirpCreated        = "chirp.created"
+	EventChirpDeleted        = "chirp.deleted"
+	EventNotificationCreated = "notification.created"
+	// EventHiddenAuthorsChanged tells a user's open streams that a block
+	// or mute changed who they hide, so they reload the list.
+	EventHiddenAuthorsChanged = "hidden_authors.changed"
+)
+
+// ChirpEvents are the public events about chirps; anyone may see them.
+var ChirpEvents = []string{EventChirpCreated, EventChirpDeleted}
+
+// StreamedEvents are the outbox event types forwarded to subscribers. Events
+// beyond ChirpEvents carry a RecipientID and must only reach that user.
+var StreamedEvents = []string{EventChirpCreated, EventChirpDeleted, EventNotificationCreated, EventHiddenAuthorsChanged}
+
+// Cursor is a position in the stream. Outbox IDs are allocated before
+// commit, so a later ID can become visible first; events are instead
+// ordered by their writing transaction and then by ID, and only published
+// once every older transaction has finished, which makes a cursor safe to
+// resume from.
+type Cursor struct {
+	Txid int64
+	ID   int64
+}
+
+// ParseCursor parses the "txid-id" form produced by String.
+func ParseCursor(s string) (Cursor, error) {
+	txid, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	var c Cursor
+	var err error
+	if c.Txid, err = strconv.ParseInt(txid, 10, 64); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+func (c Cursor) String() string {
+	return strconv.FormatInt(c.Txid, 10) + "-" + strconv.FormatInt(c.ID, 10)
+}
+
+// After reports whether c comes later in the stream than o.
+func (c Cursor) After(o Cursor) bool {
+	return c.Txid > o.Txid || (c.Txid == o.Txid && c.ID > o.ID)
+}
+
+// Event is a single stream message. ID is the outbox event ID and Cursor
+// its position in the stream, which doubles as the SSE event ID for
+// resuming. ParentID is the chirp a reply answers, and RecipientID is set
+// for events addressed to a single user.
+type Event struct {
+	ID          int64
+	Cursor      Cursor
+	Type        string
+	AuthorID    uuid.UUID
+	ChirpID     uuid.UUID
+	ParentID    uuid.UUID
+	RecipientID uuid.UUID
+	Data        json.RawMessage
+}
+
+// EventFromOutbox converts an outbox row into a stream event. It reports
+// false for event types that are not streamed.
+func EventFromOutbox(e database.OutboxEvent) (Event, bool) {
+	streamed := false
+	for _, t := range StreamedEvents {
+		if e.EventType == t {
+			streamed = true
+			break
+		}
+	}
+	if !streamed {
+		return Event{}, false
+	}
+	var payload struct {
+		ID          uuid.UUID `json:"id"`
+		UserID      uuid.UUID `json:"user_id"`
+		ReplyToID   uuid.UUID `json:"reply_to_id"`
+		RecipientID uuid.UUID `json:"recipient_id"`
+	}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return Event{}, false
+	}
+	return Event{
+		ID:          e.ID,
+		Cursor:      Cursor{Txid: e.Txid, ID: e.ID},
+		Type:        e.EventType,
+		AuthorID:    payload.UserID,
+		ChirpID:     payload.ID,
+		ParentID:    payload.ReplyToID,
+		RecipientID: payload.RecipientID,
+		Data:        e.Payload,
+	}, true
+}
+
+// IsChirpEvent reports whether e is one of ChirpEvents.
+func IsChirpEvent(e Event) bool {
+	return e.Type == EventChirpCreated || e.Type == EventChirpDeleted
+}
+
+// Filter decides whether a subscriber receives an event. A nil Filter
+// accepts everything.
+type Filter func(Event) bool
+
+// Subscription is one subscriber's view of the hub. Events are delivered on
+// C; when the subscriber falls too far behind the hub closes C and Dropped
+// reports true, so the consumer can disconnect and let the client resume.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	filter  Filter
+	hub     *Hub
+	dropped bool
+}
+
+// Dropped reports whether the hub closed the subscription because its
+// buffer was full. It is only meaningful after C has been closed.
+func (s *Subscription) Dropped() bool {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+	return s.dropped
+}
+
+// Close removes the subscription from the hub. It is safe to call more
+// than once and after the hub dropped the subscription.
+func (s *Subscription) Close() {
+	s.hub.remove(s, false)
+}
+
+// Hub broadcasts events to every matching subscription. Publish never
+// blocks: a subscriber whose buffer is full is dropped rather than
+// stalling delivery to everyone else.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscription with the given filter and buffer size.
+func (h *Hub) Subscribe(filter Filter, buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan Event, buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Len returns the number of active subscriptions.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+func (h *Hub) Publish(e Event) {
+	var slow []*Subscription
+	h.mu.RLock()
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+	for _, s := range slow {
+		h.remove(s, true)
+	}
+}
+
+func (h *Hub) remove(s *Subscription, dropped bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	s.dropped = dropped
+	close(s.ch)
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func TestHub(t *testing.T) {
+	author := uuid.New()
+
+	t.Run("fans out to every subscriber", func(t *testing.T) {
+		hub := NewHub()
+		a := hub.Subscribe(nil, 4)
+		b := hub.Subscribe(nil, 4)
+		defer a.Close()
+		defer b.Close()
+		hub.Publish(Event{ID: 1, Type: EventChirpCreated})
+		for _, s := range []*Subscription{a, b} {
+			if e := <-s.C; e.ID != 1 {
+				t.Errorf("got event %d, want 1", e.ID)
+			}
+		}
+	})
+
+	t.Run("applies filters", func(t *testing.T) {
+		hub := NewHub()
+		s := hub.Subscribe(func(e Event) bool { return e.AuthorID == author }, 4)
+		defer s.Close()
+		hub.Publish(Event{ID: 1, AuthorID: uuid.New()})
+		hub.Publish(Event{ID: 2, AuthorID: author})
+		if e := <-s.C; e.ID != 2 {
+			t.Errorf("got event %d, want 2", e.ID)
+		}
+		if len(s.C) != 0 {
+			t.Errorf("filtered event was delivered")
+		}
+	})
+
+	t.Run("drops slow subscribers", func(t *testing.T) {
+		hub := NewHub()
+		slow := hub.Subscribe(nil, 1)
+		fast := hub.Subscribe(nil, 4)
+		defer fast.Close()
+		hub.Publish(Event{ID: 1})
+		hub.Publish(Event{ID: 2})
+		<-slow.C
+		if _, ok := <-slow.C; ok {
+			t.Fatal("slow subscriber channel still open")
+		}
+		if !slow.Dropped() {
+			t.Error("Dropped() = false for slow subscriber")
+		}
+		if len(fast.C) != 2 {
+			t.Errorf("fast subscriber has %d events, want 2", len(fast.C))
+		}
+		if hub.Len() != 1 {
+			t.Errorf("hub has %d subscribers, want 1", hub.Len())
+		}
+	})
+
+	t.Run("close is idempotent", func(t *testing.T) {
+		hub := NewHub()
+		s := hub.Subscribe(nil, 1)
+		s.Close()
+		s.Close()
+		if s.Dropped() {
+			t.Error("Dropped() = true after Close")
+		}
+	})
+}
+
+func TestEventFromOutbox(t *testing.T) {
+	author := uuid.New()
+	payload, _ := json.Marshal(map[string]any{"id": uuid.New(), "user_id": author})
+
+	e, ok := EventFromOutbox(database.OutboxEvent{ID: 7, EventType: EventChirpDeleted, Payload: payload})
+	if !ok || e.ID != 7 || e.AuthorID != author {
+		t.Errorf("EventFromOutbox = %+v, %v", e, ok)
+	}
+	parent := uuid.New()
+	reply, _ := json.Marshal(map[string]any{"id": uuid.New(), "user_id": author, "reply_to_id": parent})
+	if e, ok := EventFromOutbox(database.OutboxEvent{ID: 9, EventType: EventChirpCreated, Payload: reply}); !ok || e.ParentID != parent {
+		t.Errorf("reply has parent %s, want %s", e.ParentID, parent)
+	}
+	if _, ok := EventFromOutbox(database.OutboxEvent{ID: 8, EventType: "user.upgraded", Payload: payload}); ok {
+		t.Error("user.upgraded should not be streamed")
+	}
+}
@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// NotifyChannel is the Postgres channel the outbox_events insert trigger
+// notifies with the new event ID.
+const NotifyChannel = "chirpy_events"
+
+const (
+	catchUpBatch = 500
+	// pollInterval bounds how long an event waits when it committed while
+	// an older transaction was still open and so couldn't be published on
+	// its own notification.
+	pollInterval = time.Second
+	maxBackoff   = time.Minute
+)
+
+// Listener bridges Postgres LISTEN/NOTIFY to a Hub. Notifications only
+// wake it up; events are always read in commit order from the outbox.
+type Listener struct {
+	dsn    string
+	db     *database.Queries
+	hub    *Hub
+	cursor Cursor
+	// started is set once the cursor has been placed at the end of the
+	// outbox, so reconnecting resumes rather than skipping ahead.
+	started bool
+}
+
+func NewListener(dsn string, db *database.Queries, hub *Hub) *Listener {
+	return &Listener{dsn: dsn, db: db, hub: hub}
+}
+
+// Run listens until ctx is cancelled, retrying with backoff when the
+// listener can't be set up. Events written while the connection was down
+// are recovered from the outbox after reconnecting.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("stream: listener failed, retrying in %s: %s", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// listen runs one listener until ctx is cancelled. It only returns early
+// with an error if listening can't start.
+func (l *Listener) listen(ctx context.Context) error {
+	if !l.started {
+		horizon, err := l.db.GetOutboxHorizon(ctx)
+		if err != nil {
+			return err
+		}
+		// Everything from the oldest open transaction on is still to come.
+		l.cursor = Cursor{Txid: horizon - 1, ID: math.MaxInt64}
+		l.started = true
+	}
+
+	pl := pq.NewListener(l.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stream: listener event %d: %s", ev, err)
+		}
+	})
+	defer pl.Close()
+	if err := pl.Listen(NotifyChannel); err != nil {
+		return err
+	}
+	l.catchUp(ctx)
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-pl.Notify:
+			// A nil notification means the connection was re-established;
+			// either way, read whatever has committed since the cursor.
+			l.catchUp(ctx)
+		case <-poll.C:
+			l.catchUp(ctx)
+		case <-ping.C:
+			go pl.Ping()
+		}
+	}
+}
+
+func (l *Listener) catchUp(ctx context.Context) {
+	for {
+		rows, err := l.db.ListCommittedOutboxEventsAfter(ctx, database.ListCommittedOutboxEventsAfterParams{
+			AfterTxid:  l.cursor.Txid,
+			AfterID:    l.cursor.ID,
+			EventTypes: StreamedEvents,
+			Limit:      catchUpBatch,
+		})
+		if err != nil {
+			log.Printf("stream: error catching up after %s: %s", l.cursor, err)
+			return
+		}
+		for _, row := range rows {
+			l.cursor = Cursor{Txid: row.Txid, ID: row.ID}
+			if e, ok := EventFromOutbox(row); ok {
+				l.hub.Publish(e)
+			}
+		}
+		if len(rows) < catchUpBatch {
+			return
+		}
+	}
+}
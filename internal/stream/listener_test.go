@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func TestCursor(t *testing.T) {
+	c, err := ParseCursor("901-12")
+	if err != nil {
+		t.Fatalf("failed to parse cursor: %v", err)
+	}
+	if c != (Cursor{Txid: 901, ID: 12}) || c.String() != "901-12" {
+		t.Errorf("parsed %+v (%s), want 901-12", c, c)
+	}
+	for _, s := range []string{"12", "901-", "x-12"} {
+		if _, err := ParseCursor(s); err == nil {
+			t.Errorf("expected error parsing %q, but got none", s)
+		}
+	}
+	// A transaction that committed later sorts later, whatever its IDs.
+	if !(Cursor{Txid: 902, ID: 3}).After(Cursor{Txid: 901, ID: 12}) {
+		t.Error("expected a later transaction to sort after an earlier one")
+	}
+	if (Cursor{Txid: 901, ID: 11}).After(Cursor{Txid: 901, ID: 12}) {
+		t.Error("expected a lower ID to sort first within a transaction")
+	}
+}
+
+var outboxEventColumns = []string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "created_at", "published_at", "attempts", "next_attempt_at", "last_error", "txid"}
+
+func TestListenerCatchUpResumesFromCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	hub := NewHub()
+	sub := hub.Subscribe(nil, 4)
+	defer sub.Close()
+	l := NewListener("", database.New(db), hub)
+	l.cursor = Cursor{Txid: 900, ID: 12}
+
+	now := time.Now()
+	payload := []byte(`{"id":"` + uuid.NewString() + `"}`)
+	// Event 11 committed after event 12 did; it still comes next.
+	mock.ExpectQuery("SELECT .* FROM outbox_events").
+		WithArgs(int64(900), int64(12), sqlmock.AnyArg(), catchUpBatch).
+		WillReturnRows(sqlmock.NewRows(outboxEventColumns).
+			AddRow(11, "chirp", uuid.New(), EventChirpCreated, payload, now, nil, 0, now, "", 901))
+	mock.ExpectQuery("SELECT .* FROM outbox_events").
+		WithArgs(int64(901), int64(11), sqlmock.AnyArg(), catchUpBatch).
+		WillReturnRows(sqlmock.NewRows(outboxEventColumns))
+
+	l.catchUp(context.Background())
+	l.catchUp(context.Background())
+	if e := <-sub.C; e.ID != 11 || e.Cursor.String() != "901-11" {
+		t.Errorf("got event %d at %s, want 11 at 901-11", e.ID, e.Cursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,54 @@
+package unfurl
+
+import (
+	"context"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error) {
+	rows, err := s.db.ClaimDueLinkPreviews(ctx, database.ClaimDueLinkPreviewsParams{
+		LeaseUntil: leaseUntil,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		jobs = append(jobs, Job{URL: row.Url, Attempts: int(row.Attempts)})
+	}
+	return jobs, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, p Preview) error {
+	return s.db.CompleteLinkPreview(ctx, database.CompleteLinkPreviewParams{
+		Title:       p.Title,
+		Description: p.Description,
+		ImageUrl:    p.ImageURL,
+		SiteName:    p.SiteName,
+		Url:         p.URL,
+	})
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, url string, dead bool, nextAttempt time.Time, errMsg string) error {
+	status := "pending"
+	if dead {
+		status = "failed"
+	}
+	return s.db.FailLinkPreview(ctx, database.FailLinkPreviewParams{
+		Status:        status,
+		NextAttemptAt: nextAttempt,
+		LastError:     errMsg,
+		Url:           url,
+	})
+}
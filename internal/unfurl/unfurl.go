@@ -0,0 +1,185 @@
+// Package unfurl fetches OpenGraph and Twitter card metadata for links in
+// chirps. Fetching arbitrary user-supplied URLs from inside our network is
+// a classic SSRF vector, so the Fetcher only connects to public addresses,
+// bounds every phase with a timeout and reads a capped amount of HTML.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jcfullmer/chirpy/internal/netguard"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxRedirects         = 3
+)
+
+var ErrNotHTML = errors.New("response is not HTML")
+
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+}
+
+type Fetcher struct {
+	client    *http.Client
+	MaxBytes  int64
+	UserAgent string
+}
+
+// NewFetcher returns a Fetcher that refuses to connect to loopback,
+// private, link-local and other non-public addresses.
+func NewFetcher() *Fetcher {
+	return newFetcher(netguard.Control)
+}
+
+func newFetcher(control func(network, address string, c syscall.RawConn) error) *Fetcher {
+	transport := netguard.NewTransportWithControl(control)
+	transport.ResponseHeaderTimeout = 3 * time.Second
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   8 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkURL(req.URL)
+			},
+		},
+		MaxBytes:  512 << 10,
+		UserAgent: "Chirpy-Unfurl/1.0",
+	}
+}
+
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("invalid url %q", u.Redacted())
+	}
+	return nil
+}
+
+// Fetch downloads rawURL and extracts its preview metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if err := checkURL(u); err != nil {
+		return Preview{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes))
+	if err != nil {
+		return Preview{}, err
+	}
+	p := ParseHTML(string(body), resp.Request.URL)
+	p.URL = rawURL
+	return p, nil
+}
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s([^>]*)>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// ParseHTML extracts preview metadata from a (possibly truncated) HTML
+// document. OpenGraph tags win over Twitter card tags, which win over the
+// plain <title> and description. Relative image URLs resolve against base.
+func ParseHTML(doc string, base *url.URL) Preview {
+	doc = strings.ToValidUTF8(doc, "�")
+	meta := map[string]string{}
+	for _, m := range metaTagPattern.FindAllStringSubmatch(doc, -1) {
+		attrs := map[string]string{}
+		for _, a := range attributePattern.FindAllStringSubmatch(m[1], -1) {
+			attrs[strings.ToLower(a[1])] = a[2] + a[3] + a[4]
+		}
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = clean(attrs["content"])
+		}
+	}
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := meta[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p := Preview{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		SiteName:    first("og:site_name", "twitter:site"),
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(doc); m != nil {
+			p.Title = clean(m[1])
+		}
+	}
+	p.Title = truncate(p.Title, maxTitleLength)
+	p.Description = truncate(p.Description, maxDescriptionLength)
+	if img := first("og:image", "og:image:url", "twitter:image", "twitter:image:src"); img != "" {
+		if u, err := url.Parse(img); err == nil {
+			if base != nil {
+				u = base.ResolveReference(u)
+			}
+			if u.Scheme == "http" || u.Scheme == "https" {
+				p.ImageURL = u.String()
+			}
+		}
+	}
+	return p
+}
+
+func clean(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
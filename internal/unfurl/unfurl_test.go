@@ -0,0 +1,149 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcfullmer/chirpy/internal/netguard"
+)
+
+func TestParseHTML(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	t.Run("prefers OpenGraph", func(t *testing.T) {
+		doc := `<html><head><title>Plain</title>
+			<meta name="twitter:title" content="Card">
+			<meta property="og:title" content="Open &amp; Graph">
+			<meta content='Desc' property='og:description'>
+			<meta property="og:image" content="/img.png">
+			<meta property="og:site_name" content="Example"></head>`
+		p := ParseHTML(doc, base)
+		want := Preview{Title: "Open & Graph", Description: "Desc", ImageURL: "https://example.com/img.png", SiteName: "Example"}
+		if p != want {
+			t.Errorf("got %+v, want %+v", p, want)
+		}
+	})
+
+	t.Run("falls back to title and description", func(t *testing.T) {
+		p := ParseHTML(`<title>
+			Hello   world </title><meta name="description" content="About">`, base)
+		if p.Title != "Hello world" || p.Description != "About" {
+			t.Errorf("got %+v", p)
+		}
+	})
+
+	t.Run("drops non-http images", func(t *testing.T) {
+		p := ParseHTML(`<meta property="og:image" content="javascript:alert(1)">`, base)
+		if p.ImageURL != "" {
+			t.Errorf("ImageURL = %q", p.ImageURL)
+		}
+	})
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<meta property="og:title" content="Hi">`)
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, strings.Repeat(" ", 2048)+`<title>too late</title>`)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("blocks loopback targets", func(t *testing.T) {
+		_, err := NewFetcher().Fetch(context.Background(), srv.URL+"/page")
+		if !errors.Is(err, netguard.ErrBlockedAddress) {
+			t.Errorf("err = %v, want netguard.ErrBlockedAddress", err)
+		}
+	})
+
+	permissive := newFetcher(nil)
+
+	t.Run("reads previews", func(t *testing.T) {
+		p, err := permissive.Fetch(context.Background(), srv.URL+"/page")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Title != "Hi" || p.URL != srv.URL+"/page" {
+			t.Errorf("got %+v", p)
+		}
+	})
+
+	t.Run("caps the body", func(t *testing.T) {
+		capped := newFetcher(nil)
+		capped.MaxBytes = 1024
+		p, err := capped.Fetch(context.Background(), srv.URL+"/big")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Title != "" {
+			t.Errorf("read past the cap: %+v", p)
+		}
+	})
+
+	t.Run("rejects non-HTML", func(t *testing.T) {
+		if _, err := permissive.Fetch(context.Background(), srv.URL+"/json"); !errors.Is(err, ErrNotHTML) {
+			t.Errorf("err = %v, want ErrNotHTML", err)
+		}
+	})
+
+	t.Run("rejects other schemes", func(t *testing.T) {
+		if _, err := permissive.Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+			t.Error("file URL was fetched")
+		}
+	})
+}
+
+type memStore struct {
+	jobs      []Job
+	completed []Preview
+	failed    map[string]bool
+}
+
+func (s *memStore) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error) {
+	jobs := s.jobs
+	s.jobs = nil
+	return jobs, nil
+}
+
+func (s *memStore) Complete(ctx context.Context, p Preview) error {
+	s.completed = append(s.completed, p)
+	return nil
+}
+
+func (s *memStore) Fail(ctx context.Context, url string, dead bool, nextAttempt time.Time, errMsg string) error {
+	s.failed[url] = dead
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	store := &memStore{
+		jobs:   []Job{{URL: "http://127.0.0.1:1/"}, {URL: "http://127.0.0.1:1/again", Attempts: 2}},
+		failed: map[string]bool{},
+	}
+	w := NewWorker(store, NewFetcher())
+	w.Concurrency = 1
+	n, err := w.ProcessDue(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("ProcessDue = %d, %v", n, err)
+	}
+	if dead, ok := store.failed["http://127.0.0.1:1/"]; !ok || dead {
+		t.Errorf("first attempt should be retried, failed = %v", store.failed)
+	}
+	if !store.failed["http://127.0.0.1:1/again"] {
+		t.Errorf("third attempt should give up, failed = %v", store.failed)
+	}
+}
@@ -0,0 +1,103 @@
+package unfurl
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type Job struct {
+	URL      string
+	Attempts int
+}
+
+// Store is the durable queue of URLs waiting to be unfurled.
+type Store interface {
+	ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]Job, error)
+	Complete(ctx context.Context, p Preview) error
+	Fail(ctx context.Context, url string, dead bool, nextAttempt time.Time, errMsg string) error
+}
+
+// Worker fetches previews for queued URLs in the background, so creating a
+// chirp never waits on a third-party site.
+type Worker struct {
+	store        Store
+	fetcher      *Fetcher
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+	BatchSize    int
+	Concurrency  int
+	PollInterval time.Duration
+	Lease        time.Duration
+	now          func() time.Time
+}
+
+func NewWorker(store Store, fetcher *Fetcher) *Worker {
+	return &Worker{
+		store:        store,
+		fetcher:      fetcher,
+		MaxAttempts:  3,
+		MaxBackoff:   time.Hour,
+		BatchSize:    16,
+		Concurrency:  4,
+		PollInterval: 2 * time.Second,
+		Lease:        2 * time.Minute,
+		now:          time.Now,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.ProcessDue(ctx); err != nil {
+			log.Printf("unfurl error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue unfurls one batch of due URLs and reports how many were
+// attempted.
+func (w *Worker) ProcessDue(ctx context.Context) (int, error) {
+	jobs, err := w.store.ClaimDue(ctx, w.BatchSize, w.now().Add(w.Lease))
+	if err != nil {
+		return 0, err
+	}
+	sem := make(chan struct{}, max(1, w.Concurrency))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := w.process(ctx, job); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(job)
+	}
+	wg.Wait()
+	return len(jobs), firstErr
+}
+
+func (w *Worker) process(ctx context.Context, job Job) error {
+	p, fetchErr := w.fetcher.Fetch(ctx, job.URL)
+	if fetchErr == nil {
+		return w.store.Complete(ctx, p)
+	}
+	attempt := job.Attempts + 1
+	backoff := min(time.Duration(attempt*attempt)*time.Minute, w.MaxBackoff)
+	return w.store.Fail(ctx, job.URL, attempt >= w.MaxAttempts, w.now().Add(backoff), fetchErr.Error())
+}
@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+)
+
+// OutboxSink fans outbox events out to the endpoints subscribed to them,
+// so a webhook is queued if and only if the change that caused it was
+// committed. Deliveries are keyed by outbox event, which makes publishing
+// the same event again harmless.
+type OutboxSink struct {
+	store *PostgresStore
+}
+
+func NewOutboxSink(store *PostgresStore) *OutboxSink {
+	return &OutboxSink{store: store}
+}
+
+func (s *OutboxSink) Name() string { return "webhooks" }
+
+func (s *OutboxSink) Publish(ctx context.Context, e outbox.Event) error {
+	if !IsSupportedEvent(e.Type) {
+		return nil
+	}
+	// Every supported event is about a user, named by user_id.
+	var subject struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	if err := json.Unmarshal(e.Payload, &subject); err != nil {
+		return fmt.Errorf("decoding %s payload: %w", e.Type, err)
+	}
+	_, err := s.store.Enqueue(ctx, e.ID, e.Type, subject.UserID, e.Payload)
+	return err
+}
@@ -0,0 +1,40 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+)
+
+func TestOutboxSink(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sink := NewOutboxSink(NewPostgresStore(database.New(db)))
+
+	userID := uuid.New()
+	payload := json.RawMessage(`{"id":"` + uuid.NewString() + `","user_id":"` + userID.String() + `"}`)
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs(int64(7), EventChirpDeleted, payload, userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	err = sink.Publish(context.Background(), outbox.Event{ID: 7, Type: EventChirpDeleted, Payload: payload})
+	if err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	// Events no endpoint can subscribe to are not fanned out.
+	err = sink.Publish(context.Background(), outbox.Event{ID: 8, Type: "notification.created", Payload: payload})
+	if err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Enqueue records a delivery of outbox event outboxEventID for every
+// endpoint subscribed to event that may see events about subjectUserID.
+func (s *PostgresStore) Enqueue(ctx context.Context, outboxEventID int64, event string, subjectUserID uuid.UUID, payload json.RawMessage) (int64, error) {
+	return s.db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		OutboxEventID: outboxEventID,
+		EventType:     event,
+		Payload:       payload,
+		SubjectUserID: subjectUserID,
+	})
+}
+
+func (s *PostgresStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	rows, err := s.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseSeconds: lease.Seconds(),
+		Limit:        int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, Delivery{
+			ID:         row.ID,
+			EndpointID: row.EndpointID,
+			EventType:  row.EventType,
+			Payload:    row.Payload,
+			Attempts:   int(row.Attempts),
+			CreatedAt:  row.CreatedAt,
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *PostgresStore) Endpoint(ctx context.Context, id uuid.UUID) (Endpoint, error) {
+	e, err := s.db.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return Endpoint{
+		ID:     e.ID,
+		URL:    e.Url,
+		Secret: e.Secret,
+		Active: e.Active,
+	}, nil
+}
+
+func (s *PostgresStore) MarkSucceeded(ctx context.Context, id uuid.UUID, statusCode int) error {
+	return s.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: true},
+		ID:             id,
+	})
+}
+
+func (s *PostgresStore) MarkFailed(ctx context.Context, id uuid.UUID, dead bool, retryIn time.Duration, statusCode int, errMsg string) error {
+	status := "pending"
+	if dead {
+		status = "dead"
+	}
+	return s.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		Status:         status,
+		RetrySeconds:   retryIn.Seconds(),
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      errMsg,
+		ID:             id,
+	})
+}
@@ -0,0 +1,227 @@
+// Package webhooks delivers Chirpy events to endpoints registered by users
+// and admins. Deliveries are queued in Postgres and sent by a Dispatcher
+// that signs each request and retries failures with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/netguard"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserUpgraded = "user.upgraded"
+
+	SignatureHeader = "X-Chirpy-Signature"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+)
+
+var SupportedEvents = []string{EventChirpCreated, EventChirpDeleted, EventUserUpgraded}
+
+func IsSupportedEvent(event string) bool {
+	for _, e := range SupportedEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type Delivery struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+	EventType  string
+	Payload    json.RawMessage
+	Attempts   int
+	CreatedAt  time.Time
+}
+
+type Endpoint struct {
+	ID     uuid.UUID
+	URL    string
+	Secret string
+	Active bool
+}
+
+// Store is the durable queue the Dispatcher works from. Leases and retry
+// delays are durations so the store can measure them against its own
+// clock.
+type Store interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	Endpoint(ctx context.Context, id uuid.UUID) (Endpoint, error)
+	MarkSucceeded(ctx context.Context, id uuid.UUID, statusCode int) error
+	MarkFailed(ctx context.Context, id uuid.UUID, dead bool, retryIn time.Duration, statusCode int, errMsg string) error
+}
+
+type Dispatcher struct {
+	store        Store
+	client       *http.Client
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	now          func() time.Time
+}
+
+// NewClient returns the client deliveries should be sent with. Endpoint
+// URLs come from users, so it only connects to public addresses and
+// doesn't follow redirects.
+func NewClient() *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: netguard.NewTransport(),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		client:       client,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		BatchSize:    20,
+		PollInterval: 2 * time.Second,
+		Lease:        time.Minute,
+		now:          time.Now,
+	}
+}
+
+// Backoff returns the delay before retry number attempt (starting at 1),
+// doubling from base and capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DispatchDue(ctx); err != nil {
+			log.Printf("webhook dispatch error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue sends one batch of due deliveries and reports how many were
+// attempted.
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	deliveries, err := d.store.ClaimDue(ctx, d.BatchSize, d.Lease)
+	if err != nil {
+		return 0, err
+	}
+	endpoints := map[uuid.UUID]Endpoint{}
+	for _, delivery := range deliveries {
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = d.store.Endpoint(ctx, delivery.EndpointID)
+			if err != nil {
+				// Retry this one later rather than leaving the rest of the
+				// batch leased until the lease runs out.
+				if err := d.fail(ctx, delivery, 0, fmt.Errorf("loading endpoint: %w", err)); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+		if !endpoint.Active {
+			err = d.store.MarkFailed(ctx, delivery.ID, true, 0, 0, "endpoint disabled")
+		} else {
+			err = d.attempt(ctx, delivery, endpoint)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery, endpoint Endpoint) error {
+	statusCode, sendErr := d.send(ctx, delivery, endpoint)
+	if sendErr == nil {
+		return d.store.MarkSucceeded(ctx, delivery.ID, statusCode)
+	}
+	return d.fail(ctx, delivery, statusCode, sendErr)
+}
+
+// fail records a failed attempt, scheduling a retry with backoff or giving
+// up after MaxAttempts.
+func (d *Dispatcher) fail(ctx context.Context, delivery Delivery, statusCode int, err error) error {
+	attempt := delivery.Attempts + 1
+	dead := attempt >= d.MaxAttempts
+	retryIn := Backoff(attempt, d.BaseBackoff, d.MaxBackoff)
+	return d.store.MarkFailed(ctx, delivery.ID, dead, retryIn, statusCode, err.Error())
+}
+
+type envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery, endpoint Endpoint) (int, error) {
+	body, err := json.Marshal(envelope{
+		ID:        delivery.ID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, "v1="+auth.SignWebhook(endpoint.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,204 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+)
+
+type memoryStore struct {
+	mu          sync.Mutex
+	endpoint    Endpoint
+	endpointErr error
+	deliveries  map[uuid.UUID]*memoryDelivery
+	now         func() time.Time
+}
+
+type memoryDelivery struct {
+	Delivery
+	status      string
+	nextAttempt time.Time
+	lastCode    int
+}
+
+func newMemoryStore(endpoint Endpoint, deliveries ...Delivery) *memoryStore {
+	s := &memoryStore{endpoint: endpoint, deliveries: map[uuid.UUID]*memoryDelivery{}, now: time.Now}
+	for _, d := range deliveries {
+		s.deliveries[d.ID] = &memoryDelivery{Delivery: d, status: "pending"}
+	}
+	return s
+}
+
+func (s *memoryStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	due := []Delivery{}
+	for _, d := range s.deliveries {
+		if d.status == "pending" && !d.nextAttempt.After(now) && len(due) < limit {
+			d.nextAttempt = now.Add(lease)
+			due = append(due, d.Delivery)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) Endpoint(ctx context.Context, id uuid.UUID) (Endpoint, error) {
+	if s.endpointErr != nil {
+		return Endpoint{}, s.endpointErr
+	}
+	return s.endpoint, nil
+}
+
+func (s *memoryStore) MarkSucceeded(ctx context.Context, id uuid.UUID, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.status, d.lastCode = "succeeded", statusCode
+	d.Attempts++
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, id uuid.UUID, dead bool, retryIn time.Duration, statusCode int, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.status, d.nextAttempt, d.lastCode = "pending", s.now().Add(retryIn), statusCode
+	if dead {
+		d.status = "dead"
+	}
+	d.Attempts++
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := Backoff(i+1, base, max); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	const secret = "endpoint-secret"
+	now := time.Unix(1_700_000_000, 0)
+	delivery := Delivery{
+		ID:         uuid.New(),
+		EndpointID: uuid.New(),
+		EventType:  EventChirpCreated,
+		Payload:    json.RawMessage(`{"id":"123","body":"hello"}`),
+		CreatedAt:  now,
+	}
+
+	t.Run("Signed Delivery", func(t *testing.T) {
+		var received envelope
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+			if err != nil {
+				t.Errorf("bad timestamp header: %v", err)
+			}
+			if got, want := r.Header.Get(SignatureHeader), "v1="+auth.SignWebhook(secret, ts, body); got != want {
+				t.Errorf("signature = %q, want %q", got, want)
+			}
+			if got := r.Header.Get(EventHeader); got != EventChirpCreated {
+				t.Errorf("event header = %q", got)
+			}
+			json.Unmarshal(body, &received)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		store := newMemoryStore(Endpoint{ID: delivery.EndpointID, URL: server.URL, Secret: secret, Active: true}, delivery)
+		store.now = func() time.Time { return now }
+		d := NewDispatcher(store, server.Client())
+		d.now = store.now
+		n, err := d.DispatchDue(context.Background())
+		if err != nil || n != 1 {
+			t.Fatalf("DispatchDue = %d, %v", n, err)
+		}
+		if got := store.deliveries[delivery.ID].status; got != "succeeded" {
+			t.Errorf("status = %q, want succeeded", got)
+		}
+		if received.ID != delivery.ID || received.Type != EventChirpCreated {
+			t.Errorf("unexpected envelope %+v", received)
+		}
+	})
+
+	t.Run("Retry Then Dead", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		store := newMemoryStore(Endpoint{ID: delivery.EndpointID, URL: server.URL, Secret: secret, Active: true}, delivery)
+		d := NewDispatcher(store, server.Client())
+		d.MaxAttempts = 3
+		clock := now
+		store.now = func() time.Time { return clock }
+		d.now = store.now
+
+		for i := 1; i <= 3; i++ {
+			if _, err := d.DispatchDue(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := store.deliveries[delivery.ID]
+			if got.lastCode != http.StatusInternalServerError {
+				t.Errorf("attempt %d: last code %d", i, got.lastCode)
+			}
+			if i < 3 {
+				wantNext := clock.Add(Backoff(i, d.BaseBackoff, d.MaxBackoff))
+				if !got.nextAttempt.Equal(wantNext) || got.status != "pending" {
+					t.Errorf("attempt %d: next %v status %q, want %v pending", i, got.nextAttempt, got.status, wantNext)
+				}
+				// Not due yet, so nothing should be sent.
+				if n, _ := d.DispatchDue(context.Background()); n != 0 {
+					t.Errorf("attempt %d: dispatched %d deliveries before backoff elapsed", i, n)
+				}
+				clock = got.nextAttempt
+			}
+		}
+		if got := store.deliveries[delivery.ID].status; got != "dead" {
+			t.Errorf("status = %q, want dead", got)
+		}
+		if calls != 3 {
+			t.Errorf("receiver called %d times, want 3", calls)
+		}
+	})
+
+	t.Run("Endpoint Lookup Fails", func(t *testing.T) {
+		other := Delivery{ID: uuid.New(), EndpointID: uuid.New(), EventType: EventChirpCreated, CreatedAt: now}
+		store := newMemoryStore(Endpoint{}, delivery, other)
+		store.endpointErr = errors.New("connection reset")
+		store.now = func() time.Time { return now }
+		d := NewDispatcher(store, http.DefaultClient)
+		d.now = store.now
+
+		n, err := d.DispatchDue(context.Background())
+		if err != nil || n != 2 {
+			t.Fatalf("DispatchDue = %d, %v; want 2, nil", n, err)
+		}
+		// Both deliveries are retried with backoff instead of the batch
+		// stopping at the first error.
+		for _, id := range []uuid.UUID{delivery.ID, other.ID} {
+			got := store.deliveries[id]
+			if got.status != "pending" || got.Attempts != 1 || !got.nextAttempt.Equal(now.Add(d.BaseBackoff)) {
+				t.Errorf("delivery %s: status %q attempts %d next %v", id, got.status, got.Attempts, got.nextAttempt)
+			}
+		}
+	})
+}
@@ -1,24 +1,58 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/jcfullmer/chirpy/internal/audit"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/blob"
 	database "github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/entitlements"
+	"github.com/jcfullmer/chirpy/internal/moderation"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+	"github.com/jcfullmer/chirpy/internal/ratelimit"
+	"github.com/jcfullmer/chirpy/internal/spam"
+	"github.com/jcfullmer/chirpy/internal/stream"
+	"github.com/jcfullmer/chirpy/internal/unfurl"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	JWTSecret      string
-	PolkaKey       string
+	fileserverHits  atomic.Int32
+	db              *database.Queries
+	sqlDB           *sql.DB
+	platform        string
+	JWTSecret       string
+	PolkaKeys       []string
+	polkaVerifier   *auth.WebhookVerifier
+	polkaSignedOnly bool
+	audit           *audit.Recorder
+	entitlements    *entitlements.Catalog
+	webhooks        *webhooks.PostgresStore
+	streamHub       *stream.Hub
+	blobs           blob.Store
+	maxMediaBytes   int64
+	unattachedTTL   time.Duration // how long an upload may go unattached
+	maxChirpMedia   int
+	maxPinned       int
+	moderationRules []moderation.Rule
+	moderation      atomic.Pointer[moderation.Filter]
+	rateLimiter     *ratelimit.Limiter
+	spam            spam.Config
+	// trustProxy makes rate limiting key on X-Forwarded-For; only
+	// set it behind a proxy that overwrites the header.
+	trustProxy bool
 }
 
 func main() {
@@ -29,30 +63,228 @@ func main() {
 		log.Printf("error opening database: %s", err)
 	}
 	dbQueries := database.New(db)
+	polkaTolerance := 5 * time.Minute
+	if s := os.Getenv("POLKA_WEBHOOK_TOLERANCE"); s != "" {
+		polkaTolerance, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("invalid POLKA_WEBHOOK_TOLERANCE: %s", err)
+		}
+	}
+	catalog := entitlements.DefaultCatalog()
+	if path := os.Getenv("ENTITLEMENTS_FILE"); path != "" {
+		catalog, err = entitlements.Load(path)
+		if err != nil {
+			log.Fatalf("error loading entitlements: %s", err)
+		}
+	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "data/media"
+	}
+	blobs, err := blob.NewLocalStore(mediaDir)
+	if err != nil {
+		log.Fatalf("error opening media store: %s", err)
+	}
+	maxMediaBytes := int64(5 << 20)
+	if s := os.Getenv("MEDIA_MAX_BYTES"); s != "" {
+		if maxMediaBytes, err = strconv.ParseInt(s, 10, 64); err != nil || maxMediaBytes <= 0 {
+			log.Fatalf("invalid MEDIA_MAX_BYTES: %q", s)
+		}
+	}
+	unattachedTTL := 24 * time.Hour
+	if s := os.Getenv("MEDIA_UNATTACHED_TTL"); s != "" {
+		if unattachedTTL, err = time.ParseDuration(s); err != nil || unattachedTTL <= 0 {
+			log.Fatalf("invalid MEDIA_UNATTACHED_TTL: %q", s)
+		}
+	}
+	maxChirpMedia := 4
+	if s := os.Getenv("MAX_CHIRP_MEDIA"); s != "" {
+		if maxChirpMedia, err = strconv.Atoi(s); err != nil || maxChirpMedia < 0 {
+			log.Fatalf("invalid MAX_CHIRP_MEDIA: %q", s)
+		}
+	}
+	maxPinned := 3
+	if s := os.Getenv("MAX_PINNED_CHIRPS"); s != "" {
+		if maxPinned, err = strconv.Atoi(s); err != nil || maxPinned < 0 {
+			log.Fatalf("invalid MAX_PINNED_CHIRPS: %q", s)
+		}
+	}
+	moderationRules := moderation.DefaultRules()
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		moderationRules, err = moderation.LoadRules(path)
+		if err != nil {
+			log.Fatalf("error loading moderation words: %s", err)
+		}
+	}
+	moderationFilter, err := moderation.NewFilter(moderationRules)
+	if err != nil {
+		log.Fatalf("invalid moderation words: %s", err)
+	}
+	rateLimits := ratelimit.DefaultConfig()
+	if path := os.Getenv("RATE_LIMITS_FILE"); path != "" {
+		rateLimits, err = ratelimit.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("error loading rate limits: %s", err)
+		}
+	}
+	var rateLimitStore ratelimit.Store
+	switch s := os.Getenv("RATE_LIMIT_STORE"); s {
+	case "", "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = ratelimit.NewPostgresStore(dbQueries, ratelimit.MaxPeriod(rateLimits))
+	default:
+		log.Fatalf("invalid RATE_LIMIT_STORE: %q", s)
+	}
+	spamConfig := spam.DefaultConfig()
+	if path := os.Getenv("SPAM_CONFIG_FILE"); path != "" {
+		spamConfig, err = spam.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("error loading spam config: %s", err)
+		}
+	}
 	const filepathRoot = "."
 	const port = "8080"
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       os.Getenv("PLATFORM"),
-		JWTSecret:      os.Getenv("JWT_SECRET"),
-		PolkaKey:       os.Getenv("POLKA_KEY"),
+		fileserverHits:  atomic.Int32{},
+		db:              dbQueries,
+		sqlDB:           db,
+		platform:        os.Getenv("PLATFORM"),
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		PolkaKeys:       envList("POLKA_KEY"),
+		polkaVerifier:   auth.NewWebhookVerifier(envList("POLKA_WEBHOOK_SECRETS"), polkaTolerance),
+		polkaSignedOnly: os.Getenv("POLKA_REQUIRE_SIGNATURE") == "true",
+		audit:           audit.NewRecorder(dbQueries),
+		entitlements:    catalog,
+		webhooks:        webhooks.NewPostgresStore(dbQueries),
+		streamHub:       stream.NewHub(),
+		blobs:           blobs,
+		maxMediaBytes:   maxMediaBytes,
+		unattachedTTL:   unattachedTTL,
+		maxChirpMedia:   maxChirpMedia,
+		maxPinned:       maxPinned,
+		moderationRules: moderationRules,
+		rateLimiter:     ratelimit.NewLimiter(rateLimitStore, rateLimits),
+		spam:            spamConfig,
+		trustProxy:      os.Getenv("TRUST_PROXY_HEADERS") == "true",
+	}
+	apiCfg.moderation.Store(moderationFilter)
+	if err := apiCfg.reloadModeration(context.Background()); err != nil {
+		log.Printf("error loading moderation words from database: %s", err)
+	}
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		n, err := dbQueries.SetUserRoleByEmail(context.Background(), database.SetUserRoleByEmailParams{
+			Role:  string(auth.RoleAdmin),
+			Email: adminEmail,
+		})
+		if err != nil {
+			log.Printf("error promoting %s to admin: %s", adminEmail, err)
+		} else if n == 0 {
+			log.Printf("ADMIN_EMAIL %s does not match any user yet", adminEmail)
+		}
 	}
+	dispatcher := webhooks.NewDispatcher(apiCfg.webhooks, webhooks.NewClient())
+	go dispatcher.Run(context.Background())
+	sinks, err := outbox.ParseSinks(os.Getenv("OUTBOX_SINKS"))
+	if err != nil {
+		log.Fatalf("invalid OUTBOX_SINKS: %s", err)
+	}
+	sinks = append(sinks, webhooks.NewOutboxSink(apiCfg.webhooks))
+	go outbox.NewRelay(dbQueries, sinks).Run(context.Background())
+	go stream.NewListener(dbURL, dbQueries, apiCfg.streamHub).Run(context.Background())
+	go apiCfg.watchModerationWords(context.Background())
+	go apiCfg.runScheduledPublisher(context.Background())
+	go apiCfg.runMediaSweeper(context.Background())
+	go unfurl.NewWorker(unfurl.NewPostgresStore(dbQueries), unfurl.NewFetcher()).Run(context.Background())
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handleMetrics)
 	mux.HandleFunc("GET /healthz", handlerReadiness)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handleReset)
-	mux.HandleFunc("POST /api/users", apiCfg.handleCreateUser)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handleCreateChirp)
+	mux.HandleFunc("POST /api/users", apiCfg.middlewareRateLimit("signup", apiCfg.handleCreateUser))
+	mux.HandleFunc("POST /api/chirps", apiCfg.middlewareRateLimit("chirps.create", apiCfg.handleCreateChirp))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
+	mux.HandleFunc("GET /api/chirps/stream", apiCfg.handleChirpStream)
+	mux.HandleFunc("POST /api/drafts", apiCfg.middlewareAuth(apiCfg.handleCreateDraft))
+	mux.HandleFunc("GET /api/drafts", apiCfg.middlewareAuth(apiCfg.handleListDrafts))
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.middlewareAuth(apiCfg.handleUpdateDraft))
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.middlewareAuth(apiCfg.handleDeleteDraft))
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.middlewareRateLimit("chirps.create", apiCfg.middlewareAuth(apiCfg.handlePublishDraft)))
+	mux.HandleFunc("GET /api/scheduled_chirps", apiCfg.middlewareAuth(apiCfg.handleListScheduledChirps))
+	mux.HandleFunc("PUT /api/scheduled_chirps/{scheduledID}", apiCfg.middlewareAuth(apiCfg.handleRescheduleChirp))
+	mux.HandleFunc("DELETE /api/scheduled_chirps/{scheduledID}", apiCfg.middlewareAuth(apiCfg.handleCancelScheduledChirp))
+	mux.HandleFunc("GET /api/ws", apiCfg.handleWebSocket)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetChirpByID)
-	mux.HandleFunc("POST /api/login", apiCfg.handleLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
+	mux.HandleFunc("POST /api/login", apiCfg.middlewareRateLimit("login", apiCfg.handleLogin))
+	mux.HandleFunc("POST /api/refresh", apiCfg.middlewareRateLimit("refresh", apiCfg.handlerRefresh))
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateLogin)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handleDeleteChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handleWebhooks)
+	mux.HandleFunc("PUT /api/users", apiCfg.middlewareAuth(apiCfg.handlerUpdateLogin))
+	mux.HandleFunc("GET /api/users/me/subscription", apiCfg.middlewareAuth(apiCfg.handleGetSubscription))
+	mux.HandleFunc("GET /api/users/me/entitlements", apiCfg.middlewareAuth(apiCfg.handleGetEntitlements))
+	mux.HandleFunc("PUT /api/users/me/handle", apiCfg.middlewareAuth(apiCfg.handleSetHandle))
+	mux.HandleFunc("GET /api/notifications", apiCfg.middlewareAuth(apiCfg.handleListNotifications))
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.middlewareAuth(apiCfg.handleMarkAllNotificationsRead))
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.middlewareAuth(apiCfg.handleMarkNotificationRead))
+	mux.HandleFunc("POST /api/media", apiCfg.middlewareRateLimit("media.upload", apiCfg.middlewareAuth(apiCfg.handleUploadMedia)))
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.handleGetMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.handleGetMediaThumbnail)
+	mux.HandleFunc("GET /api/tags/{tag}", apiCfg.handleGetTag)
+	mux.HandleFunc("GET /api/trending/tags", apiCfg.handleTrendingTags)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(apiCfg.handleLikeChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(apiCfg.handleUnlikeChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(apiCfg.handleBookmarkChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(apiCfg.handleUnbookmarkChirp))
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.middlewareAuth(apiCfg.handleListBookmarks))
+	mux.HandleFunc("PUT /api/bookmarks/{chirpID}", apiCfg.middlewareAuth(apiCfg.handleMoveBookmark))
+	mux.HandleFunc("GET /api/bookmarks/collections", apiCfg.middlewareAuth(apiCfg.handleListBookmarkCollections))
+	mux.HandleFunc("POST /api/bookmarks/collections", apiCfg.middlewareAuth(apiCfg.handleCreateBookmarkCollection))
+	mux.HandleFunc("PUT /api/bookmarks/collections/{collectionID}", apiCfg.middlewareAuth(apiCfg.handleRenameBookmarkCollection))
+	mux.HandleFunc("DELETE /api/bookmarks/collections/{collectionID}", apiCfg.middlewareAuth(apiCfg.handleDeleteBookmarkCollection))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.middlewareAuth(apiCfg.handlePinChirp))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/pin", apiCfg.middlewareAuth(apiCfg.handleMovePin))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.middlewareAuth(apiCfg.handleUnpinChirp))
+	mux.HandleFunc("GET /api/users/{userID}/chirps", apiCfg.handleGetUserChirps)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.middlewareRateLimit("reports.create", apiCfg.middlewareAuth(apiCfg.handleReportChirp)))
+	mux.HandleFunc("POST /api/users/{userID}/report", apiCfg.middlewareRateLimit("reports.create", apiCfg.middlewareAuth(apiCfg.handleReportUser)))
+	mux.HandleFunc("GET /api/users/me/warnings", apiCfg.middlewareAuth(apiCfg.handleListWarnings))
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.middlewareAuth(apiCfg.handleBlockUser))
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.middlewareAuth(apiCfg.handleUnblockUser))
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.middlewareAuth(apiCfg.handleMuteUser))
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.middlewareAuth(apiCfg.handleUnmuteUser))
+	mux.HandleFunc("GET /api/users/me/blocks", apiCfg.middlewareAuth(apiCfg.handleListBlocks))
+	mux.HandleFunc("GET /api/users/me/mutes", apiCfg.middlewareAuth(apiCfg.handleListMutes))
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.middlewareAuth(apiCfg.handleFollowUser))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.middlewareAuth(apiCfg.handleUnfollowUser))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.middlewareAuth(apiCfg.handleUpdateChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.middlewareAuth(apiCfg.handleDeleteChirp))
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.middlewareRateLimit("webhooks", apiCfg.handleWebhooks))
+	mux.HandleFunc("POST /api/webhooks", apiCfg.middlewareAuth(apiCfg.handleCreateWebhookEndpoint))
+	mux.HandleFunc("GET /api/webhooks", apiCfg.middlewareAuth(apiCfg.handleListWebhookEndpoints))
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.middlewareAuth(apiCfg.handleDeleteWebhookEndpoint))
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.middlewareAuth(apiCfg.handleListWebhookDeliveries))
+	mux.HandleFunc("POST /admin/webhooks", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleAdminCreateWebhookEndpoint))
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleAdminListWebhookEndpoints))
+	mux.HandleFunc("POST /admin/chirps/{chirpID}/hide", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleHideChirp))
+	mux.HandleFunc("DELETE /admin/chirps/{chirpID}/hide", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleUnhideChirp))
+	mux.HandleFunc("DELETE /admin/chirps/{chirpID}/shadow", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleUnshadowChirp))
+	mux.HandleFunc("GET /admin/users", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleListUsers))
+	mux.HandleFunc("PUT /admin/users/{userID}/state", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleSetAccountState))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleSetUserRole))
+	mux.HandleFunc("DELETE /admin/users/{userID}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleAdminDeleteUser))
+	mux.HandleFunc("GET /admin/actions", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleListAdminActions))
+	mux.HandleFunc("GET /admin/webhooks/events", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleListWebhookEvents))
+	mux.HandleFunc("GET /admin/webhooks/events/{eventID}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleGetWebhookEvent))
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleReplayWebhookEvent))
+	mux.HandleFunc("GET /admin/moderation/words", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleListModerationWords))
+	mux.HandleFunc("PUT /admin/moderation/words/{word}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleSetModerationWord))
+	mux.HandleFunc("DELETE /admin/moderation/words/{word}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleDeleteModerationWord))
+	mux.HandleFunc("GET /admin/moderation/flags", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleListChirpFlags))
+	mux.HandleFunc("GET /admin/reports", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleListReports))
+	mux.HandleFunc("GET /admin/reports/{reportID}", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleGetReport))
+	mux.HandleFunc("POST /admin/reports/{reportID}/claim", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleClaimReport))
+	mux.HandleFunc("POST /admin/reports/{reportID}/release", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleReleaseReport))
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.handleResolveReport))
+	mux.HandleFunc("GET /admin/audit", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handleListAuditEvents))
 	serve := http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
@@ -60,3 +292,14 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(serve.ListenAndServe())
 }
+
+// envList splits a comma separated environment variable, dropping blanks.
+func envList(name string) []string {
+	values := []string{}
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
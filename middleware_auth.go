@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+type authedHandler func(http.ResponseWriter, *http.Request, database.User)
+
+func (cfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
+	return cfg.middlewareRequireRole(auth.RoleUser, handler)
+}
+
+func (cfg *apiConfig) middlewareRequireRole(required auth.Role, handler authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "missing bearer token", err)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+			return
+		}
+		user, err := cfg.db.GetUserByID(context.Background(), userID)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "user not found", err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error looking up user", err)
+			return
+		}
+		if accountState(user) == AccountDeactivated {
+			respondWithError(w, http.StatusUnauthorized, "account is deactivated", errAccountDeactivated)
+			return
+		}
+		// Access tokens outlive a suspension's start, so a suspended user
+		// keeps read access but can't change anything until it ends.
+		if err := checkAccountActive(user); err != nil && isMutating(r) {
+			respondWithAccountError(w, user, err)
+			return
+		}
+		if !auth.Role(user.Role).Includes(required) {
+			respondWithError(w, http.StatusForbidden, "insufficient role", fmt.Errorf("user %s has role %q, needs %q", user.ID, user.Role, required))
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// isMutating reports whether r can change state, as opposed to only
+// reading it.
+func isMutating(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
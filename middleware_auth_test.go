@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+func TestMiddlewareAuthSuspendedUserIsReadOnly(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		want   int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusForbidden},
+		{http.MethodDelete, http.StatusForbidden},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			cfg.JWTSecret = "secret"
+			user := uuid.New()
+			now := time.Now()
+			mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).
+				WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(user, now, now, "user@example.com", "", "user", nil, now.UTC().Add(time.Hour), AccountSuspended))
+			token, err := auth.MakeJWT(user, cfg.JWTSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(tc.method, "/api/chirps", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, u database.User) {
+				w.WriteHeader(http.StatusOK)
+			})(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAuthExpiredSuspensionCanPost(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.JWTSecret = "secret"
+	user := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(user, now, now, "user@example.com", "", "user", nil, now.UTC().Add(-time.Hour), AccountSuspended))
+	token, err := auth.MakeJWT(user, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, u database.User) {
+		w.WriteHeader(http.StatusNoContent)
+	})(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestUpdateLoginRejectsSuspendedUser(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.JWTSecret = "secret"
+	user := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(user).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(user, now, now, "user@example.com", "", "user", nil, now.UTC().Add(time.Hour), AccountSuspended))
+	token, err := auth.MakeJWT(user, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(`{"email":"new@example.com","password":"hunter2"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	cfg.middlewareAuth(cfg.handlerUpdateLogin)(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
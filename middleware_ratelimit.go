@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jcfullmer/chirpy/internal/auth"
+	"github.com/jcfullmer/chirpy/internal/ratelimit"
+)
+
+// middlewareRateLimit applies the policies configured for route before
+// calling handler. If the bucket store is unreachable the request goes
+// through: an outage shouldn't take the API down with it.
+func (cfg *apiConfig) middlewareRateLimit(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision, err := cfg.rateLimiter.Allow(r.Context(), route, cfg.rateLimitIdentities(r))
+		if err != nil {
+			log.Printf("rate limit %s: %s", route, err)
+		} else if decision != nil {
+			decision.SetHeaders(w.Header())
+			if !decision.Allowed {
+				respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded, try again later", nil)
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// rateLimitIdentities works out who is calling without touching the
+// database beyond the plan lookup for a valid access token. API keys are
+// hashed so bucket keys never hold secrets.
+func (cfg *apiConfig) rateLimitIdentities(r *http.Request) ratelimit.Identities {
+	ids := ratelimit.Identities{IP: cfg.clientIP(r)}
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		if userID, err := auth.ValidateJWT(token, cfg.JWTSecret); err == nil {
+			ids.UserID = userID.String()
+			ids.Multiplier = cfg.entitlementsFor(userID).RateLimitMultiplier
+		}
+	}
+	if key, err := auth.GetAPIKey(r.Header); err == nil && key != "" {
+		sum := sha256.Sum256([]byte(key))
+		ids.APIKey = hex.EncodeToString(sum[:16])
+	}
+	return ids
+}
+
+// clientIP is the peer address, or the left-most X-Forwarded-For entry
+// when the server sits behind a proxy that sets it.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+const (
+	NotificationMention = "mention"
+	NotificationReply   = "reply"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+)
+
+type Notification struct {
+	ID          uuid.UUID  `json:"id"`
+	RecipientID uuid.UUID  `json:"recipient_id"`
+	ActorID     uuid.UUID  `json:"actor_id"`
+	Type        string     `json:"type"`
+	ChirpID     *uuid.UUID `json:"chirp_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReadAt      *time.Time `json:"read_at"`
+}
+
+func notificationFromDB(n database.Notification) Notification {
+	notification := Notification{
+		ID:          n.ID,
+		RecipientID: n.RecipientID,
+		ActorID:     n.ActorID,
+		Type:        n.Type,
+		CreatedAt:   n.CreatedAt,
+	}
+	if n.ChirpID.Valid {
+		notification.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		notification.ReadAt = &n.ReadAt.Time
+	}
+	return notification
+}
+
+// notify records a notification inside the caller's transaction and writes
+// it to the outbox so live subscribers see it. Notifying yourself, or
+// someone who muted or is blocked from you, is a no-op.
+func notify(ctx context.Context, q *database.Queries, recipientID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) error {
+	if recipientID == actorID {
+		return nil
+	}
+	n, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		RecipientID: recipientID,
+		ActorID:     actorID,
+		Type:        notificationType,
+		ChirpID:     chirpID,
+	})
+	if err == sql.ErrNoRows {
+		// Muted or blocked.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = outbox.Write(ctx, q, "notification", n.ID, stream.EventNotificationCreated, notificationFromDB(n))
+	return err
+}
+
+// notifyForChirp sends reply and mention notifications for a new chirp. A
+// user who is both replied to and mentioned only gets the reply.
+func notifyForChirp(ctx context.Context, q *database.Queries, c database.Chirp, mentioned []database.User) error {
+	chirpID := uuid.NullUUID{UUID: c.ID, Valid: true}
+	notified := map[uuid.UUID]bool{}
+	if c.ReplyToID.Valid {
+		parent, err := q.GetChirpByID(ctx, c.ReplyToID.UUID)
+		if err != nil {
+			return err
+		}
+		if err := notify(ctx, q, parent.UserID, c.UserID, NotificationReply, chirpID); err != nil {
+			return err
+		}
+		notified[parent.UserID] = true
+	}
+	for _, u := range mentioned {
+		if notified[u.ID] {
+			continue
+		}
+		if err := notify(ctx, q, u.ID, c.UserID, NotificationMention, chirpID); err != nil {
+			return err
+		}
+		notified[u.ID] = true
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleListNotifications(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset := parsePagination(r)
+	var notificationsDB []database.Notification
+	var err error
+	if r.URL.Query().Get("unread") == "true" {
+		notificationsDB, err = cfg.db.ListUnreadNotifications(context.Background(), database.ListUnreadNotificationsParams{
+			RecipientID: user.ID,
+			Limit:       limit,
+			Offset:      offset,
+		})
+	} else {
+		notificationsDB, err = cfg.db.ListNotifications(context.Background(), database.ListNotificationsParams{
+			RecipientID: user.ID,
+			Limit:       limit,
+			Offset:      offset,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error listing notifications", err)
+		return
+	}
+	unread, err := cfg.db.CountUnreadNotifications(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error counting notifications", err)
+		return
+	}
+	type response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+	resp := response{Notifications: []Notification{}, UnreadCount: unread}
+	for _, n := range notificationsDB {
+		resp.Notifications = append(resp.Notifications, notificationFromDB(n))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not a Valid ID", err)
+		return
+	}
+	n, err := cfg.db.MarkNotificationRead(context.Background(), database.MarkNotificationReadParams{
+		ID:          notificationID,
+		RecipientID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error marking notification read", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Notification not found", sql.ErrNoRows)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	if _, err := cfg.db.MarkAllNotificationsRead(context.Background(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error marking notifications read", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
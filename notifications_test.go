@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/stream"
+)
+
+var notificationColumns = []string{"id", "recipient_id", "actor_id", "type", "chirp_id", "created_at", "read_at"}
+
+func notificationRow(recipient, actor uuid.UUID, notificationType string) *sqlmock.Rows {
+	return sqlmock.NewRows(notificationColumns).
+		AddRow(uuid.New(), recipient, actor, notificationType, nil, time.Now(), nil)
+}
+
+func TestNotifyForChirp(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author, parentAuthor, muter := uuid.New(), uuid.New(), uuid.New()
+	parentID, chirpID := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT .* FROM chirps").WithArgs(parentID).
+		WillReturnRows(chirpRows(parentAuthor, parentID))
+	mock.ExpectQuery("INSERT INTO notifications").
+		WithArgs(parentAuthor, author, NotificationReply, chirpID).
+		WillReturnRows(notificationRow(parentAuthor, author, NotificationReply))
+	mock.ExpectQuery("INSERT INTO outbox_events").
+		WithArgs("notification", sqlmock.AnyArg(), stream.EventNotificationCreated, sqlmock.AnyArg()).
+		WillReturnRows(outboxRow(1, stream.EventNotificationCreated))
+	// The parent's author is also mentioned but only gets the reply, the
+	// author mentioning themselves gets nothing, and a user who muted the
+	// author gets nothing and no event.
+	mock.ExpectQuery("INSERT INTO notifications").
+		WithArgs(muter, author, NotificationMention, chirpID).
+		WillReturnRows(sqlmock.NewRows(notificationColumns))
+
+	c := database.Chirp{
+		ID:        chirpID,
+		UserID:    author,
+		ReplyToID: uuid.NullUUID{UUID: parentID, Valid: true},
+	}
+	mentioned := []database.User{{ID: parentAuthor}, {ID: author}, {ID: muter}}
+	if err := notifyForChirp(context.Background(), cfg.db, c, mentioned); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListUnreadNotifications(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user, actor := uuid.New(), uuid.New()
+	mock.ExpectQuery("FROM notifications\\s+WHERE recipient_id = \\$1 AND read_at IS NULL").
+		WithArgs(user, int32(defaultPageLimit), int32(0)).
+		WillReturnRows(notificationRow(user, actor, NotificationFollow))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM notifications").WithArgs(user).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	rec := httptest.NewRecorder()
+	cfg.handleListNotifications(rec, httptest.NewRequest(http.MethodGet, "/api/notifications?unread=true", nil), database.User{ID: user})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Notifications) != 1 || got.Notifications[0].ActorID != actor || got.UnreadCount != 3 {
+		t.Errorf("response = %+v, want one follow and 3 unread", got)
+	}
+}
+
+func TestMarkSomeoneElsesNotificationRead(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user, id := uuid.New(), uuid.New()
+	mock.ExpectExec("UPDATE notifications").WithArgs(id, user).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notifications/"+id.String()+"/read", nil)
+	req.SetPathValue("notificationID", id.String())
+	rec := httptest.NewRecorder()
+	cfg.handleMarkNotificationRead(rec, req, database.User{ID: user})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
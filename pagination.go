@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+func parsePagination(r *http.Request) (limit, offset int32) {
+	limit = defaultPageLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = int32(min(l, maxPageLimit))
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = int32(o)
+	}
+	return limit, offset
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/spam"
+)
+
+// scoreSpam runs the spam heuristics over a chirp author is about to post,
+// or over the new body of chirp editing when it is not uuid.Nil, which
+// mustn't count as a repeat of itself. Times are compared on the
+// database's clock, which wrote the stored ones.
+func (cfg *apiConfig) scoreSpam(ctx context.Context, author database.User, body string, editing uuid.UUID) (spam.Result, error) {
+	now, err := cfg.db.GetDatabaseTime(ctx)
+	if err != nil {
+		return spam.Result{}, err
+	}
+	recent, err := cfg.db.ListRecentChirpsByUser(ctx, database.ListRecentChirpsByUserParams{
+		UserID:        author.ID,
+		WindowSeconds: max(cfg.spam.DuplicateWindow, time.Hour).Seconds(),
+	})
+	if err != nil {
+		return spam.Result{}, err
+	}
+	in := spam.Input{Body: body, AuthorCreatedAt: author.CreatedAt, Now: now}
+	for _, r := range recent {
+		if r.ID == editing {
+			continue
+		}
+		in.Recent = append(in.Recent, spam.Recent{Body: r.Body, CreatedAt: r.CreatedAt})
+	}
+	return cfg.spam.Score(in), nil
+}
+
+// holdForSpam applies a review or shadow verdict to a chirp and puts it in
+// the moderation queue with the signals that fired. Review hides the chirp
+// until a moderator unhides it; shadow leaves it visible to its author
+// only. announced says whether the chirp has already been announced, as an
+// edited one has; releasing a chirp that wasn't announces it.
+func holdForSpam(ctx context.Context, q *database.Queries, c database.Chirp, result spam.Result, announced bool) error {
+	switch result.Verdict {
+	case spam.Review:
+		if _, err := q.HideChirp(ctx, c.ID); err != nil {
+			return err
+		}
+	case spam.Shadow:
+		if err := q.ShadowChirp(ctx, c.ID); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	if err := q.HoldChirp(ctx, database.HoldChirpParams{ChirpID: c.ID, Announced: announced}); err != nil {
+		return err
+	}
+	return raiseSystemReport(ctx, q, c, ReasonSpam, result.Summary())
+}
+
+// releaseHeldChirp ends the spam hold on chirpID once moderators have made
+// it visible again, announcing it to subscribers, webhooks and the users
+// it mentions if it was held before it was ever announced. Chirps that
+// weren't held, or are still hidden or shadowed, are left alone.
+func releaseHeldChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID) error {
+	announced, err := q.ReleaseHeldChirp(ctx, chirpID)
+	if err == sql.ErrNoRows || (err == nil && announced) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	c, err := q.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	author, err := q.GetUserByID(ctx, c.UserID)
+	if err != nil {
+		return err
+	}
+	if accountState(author) == AccountShadowBanned {
+		return nil
+	}
+	mentioned, err := storeChirpEntities(ctx, q, c.UserID, c.ID, c.Body)
+	if err != nil {
+		return err
+	}
+	chirp, err := hydrateChirp(ctx, q, c, uuid.NullUUID{})
+	if err != nil {
+		return err
+	}
+	return announceChirp(ctx, q, c, chirp, mentioned)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/spam"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
+)
+
+func TestScoreSpamIgnoresTheChirpBeingEdited(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	cfg.spam = spam.DefaultConfig()
+	author := database.User{ID: uuid.New(), CreatedAt: time.Now().Add(-365 * 24 * time.Hour)}
+	editing := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery("SELECT NOW\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(now))
+	mock.ExpectQuery("SELECT id, body, created_at FROM chirps").
+		WithArgs(author.ID, (24 * time.Hour).Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "body", "created_at"}).
+			AddRow(editing, "buy my stuff", now.Add(-time.Minute)))
+
+	result, err := cfg.scoreSpam(context.Background(), author, "buy my stuff", editing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != spam.Allow {
+		t.Errorf("verdict = %q, want allow: %s", result.Verdict, result.Summary())
+	}
+}
+
+// expectUnhide sets up an unhide of chirpID up to the point where the
+// spam hold is released, which reports announced.
+func expectUnhide(mock sqlmock.Sqlmock, author, chirpID uuid.UUID, announced bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE chirps\\s+SET hidden_at = NULL").WithArgs(chirpID).
+		WillReturnRows(chirpRows(author, chirpID))
+	mock.ExpectQuery("DELETE FROM held_chirps").WithArgs(chirpID).
+		WillReturnRows(sqlmock.NewRows([]string{"announced"}).AddRow(announced))
+}
+
+func expectAdminAction(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO admin_actions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func newUnhideRequest(chirpID uuid.UUID) *http.Request {
+	req := httptest.NewRequest(http.MethodDelete, "/admin/chirps/"+chirpID.String()+"/hide", nil)
+	req.SetPathValue("chirpID", chirpID.String())
+	return req
+}
+
+func TestUnhideHeldChirpAnnouncesIt(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author, chirpID := uuid.New(), uuid.New()
+	expectUnhide(mock, author, chirpID, false)
+	mock.ExpectQuery("SELECT .* FROM chirps").WithArgs(chirpID).WillReturnRows(chirpRows(author, chirpID))
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(author).WillReturnRows(userRow(author))
+	mock.ExpectExec("DELETE FROM chirp_hashtags").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM chirp_mentions").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM chirp_links").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM chirp_hashtags").WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	mock.ExpectQuery("FROM chirp_mentions").WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	mock.ExpectQuery("FROM attachments").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM chirp_links").WillReturnRows(sqlmock.NewRows([]string{"url"}))
+	mock.ExpectQuery("INSERT INTO outbox_events").
+		WithArgs("chirp", chirpID, webhooks.EventChirpCreated, sqlmock.AnyArg()).
+		WillReturnRows(outboxRow(1, webhooks.EventChirpCreated))
+	expectAdminAction(mock)
+
+	rec := httptest.NewRecorder()
+	cfg.handleUnhideChirp(rec, newUnhideRequest(chirpID), database.User{ID: uuid.New(), Role: "moderator"})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestUnhideAnnouncedChirpDoesNotAnnounceAgain(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	author, chirpID := uuid.New(), uuid.New()
+	expectUnhide(mock, author, chirpID, true)
+	expectAdminAction(mock)
+
+	rec := httptest.NewRecorder()
+	cfg.handleUnhideChirp(rec, newUnhideRequest(chirpID), database.User{ID: uuid.New(), Role: "moderator"})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
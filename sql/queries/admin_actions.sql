@@ -0,0 +1,17 @@
+-- name: CreateAdminAction :exec
+INSERT INTO admin_actions (id, created_at, actor_id, actor_role, action, target_type, target_id, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: ListAdminActions :many
+SELECT * FROM admin_actions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
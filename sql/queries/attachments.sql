@@ -0,0 +1,97 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, owner_id, content_type, size_bytes, width, height, blob_key, thumbnail_key, alt_text, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments
+WHERE id = $1;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = $1, position = $2, alt_text = $3
+WHERE id = $4 AND chirp_id IS NULL;
+
+-- name: ListAttachmentsForChirps :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: QueueChirpBlobDeletions :exec
+-- Run in the transaction that deletes the chirp, before its attachments
+-- cascade away.
+INSERT INTO blob_deletions (key, attempts, next_attempt_at, last_error, created_at)
+SELECT unnest(ARRAY[blob_key, thumbnail_key]), 0, NOW(), '', NOW()
+FROM attachments
+WHERE chirp_id = sqlc.arg(chirp_id)::uuid
+ON CONFLICT (key) DO NOTHING;
+
+-- name: QueueOwnerBlobDeletions :exec
+-- Run in the transaction that deletes the user, before their attachments
+-- cascade away.
+INSERT INTO blob_deletions (key, attempts, next_attempt_at, last_error, created_at)
+SELECT unnest(ARRAY[blob_key, thumbnail_key]), 0, NOW(), '', NOW()
+FROM attachments
+WHERE owner_id = $1
+ON CONFLICT (key) DO NOTHING;
+
+-- name: DeleteUnattachedMedia :many
+-- Deletes uploads that weren't attached to a chirp within the TTL. Uploads
+-- still used by a draft or a pending scheduled chirp are kept however old
+-- they are, and swept once that draft or schedule is gone. An upload being
+-- attached concurrently is either attached first and kept, or deleted
+-- first and fails to attach.
+DELETE FROM attachments
+WHERE chirp_id IS NULL
+    AND created_at < NOW() - make_interval(secs => sqlc.arg(ttl_seconds)::float8)
+    AND NOT EXISTS (
+        SELECT 1 FROM drafts
+        WHERE drafts.media @> jsonb_build_array(jsonb_build_object('id', attachments.id))
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM scheduled_chirps
+        WHERE scheduled_chirps.state = 'pending'
+            AND scheduled_chirps.media @> jsonb_build_array(jsonb_build_object('id', attachments.id))
+    )
+RETURNING *;
+
+-- name: QueueBlobDeletions :exec
+INSERT INTO blob_deletions (key, attempts, next_attempt_at, last_error, created_at)
+SELECT unnest(sqlc.arg(keys)::text[]), 0, NOW(), '', NOW()
+ON CONFLICT (key) DO NOTHING;
+
+-- name: ClaimBlobDeletions :many
+-- Pushes next_attempt_at out by the lease so that other sweepers skip the
+-- claimed blobs while they are being deleted.
+UPDATE blob_deletions
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE key IN (
+    SELECT key FROM blob_deletions
+    WHERE next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteBlobDeletion :exec
+DELETE FROM blob_deletions
+WHERE key = $1;
+
+-- name: FailBlobDeletion :exec
+UPDATE blob_deletions
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = NOW() + make_interval(secs => sqlc.arg(backoff_seconds)::float8)
+WHERE key = sqlc.arg(key);
@@ -0,0 +1,48 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: ListBlocks :many
+SELECT * FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_a)::uuid AND followee_id = sqlc.arg(user_b)::uuid)
+   OR (follower_id = sqlc.arg(user_b)::uuid AND followee_id = sqlc.arg(user_a)::uuid);
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_a)::uuid AND blocked_id = sqlc.arg(user_b)::uuid)
+       OR (blocker_id = sqlc.arg(user_b)::uuid AND blocked_id = sqlc.arg(user_a)::uuid)
+) AS blocked;
+
+-- name: MuteUser :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: ListMutes :many
+SELECT * FROM mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListHiddenAuthorIDs :many
+-- Authors whose chirps viewer shouldn't see in timelines: everyone on
+-- either side of a block, and everyone viewer muted.
+SELECT blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = sqlc.arg(viewer_id)::uuid
+UNION
+SELECT blocker_id AS user_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+UNION
+SELECT muted_id AS user_id FROM mutes WHERE mutes.muter_id = sqlc.arg(viewer_id)::uuid;
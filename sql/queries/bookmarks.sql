@@ -0,0 +1,114 @@
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections
+WHERE id = $1;
+
+-- name: ListBookmarkCollections :many
+SELECT bookmark_collections.*, COUNT(bookmarks.chirp_id) AS bookmark_count
+FROM bookmark_collections
+LEFT JOIN bookmarks ON bookmarks.collection_id = bookmark_collections.id
+WHERE bookmark_collections.user_id = $1
+GROUP BY bookmark_collections.id
+ORDER BY bookmark_collections.created_at ASC;
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET name = $1, updated_at = NOW()
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+WHERE id = $1 AND user_id = $2;
+
+-- name: AddBookmark :execrows
+-- The new bookmark goes on top of its collection. Bookmarking a chirp
+-- twice changes nothing.
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, position, created_at)
+SELECT
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(chirp_id)::uuid,
+    sqlc.narg(collection_id)::uuid,
+    COALESCE(MIN(position), 0) - 1,
+    NOW()
+FROM bookmarks
+WHERE user_id = sqlc.arg(user_id)::uuid
+    AND collection_id IS NOT DISTINCT FROM sqlc.narg(collection_id)::uuid
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: RemoveBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: MakeRoomForBookmark :exec
+-- Renumbers the other bookmarks of a collection 0, 1, 2... leaving a gap
+-- at index for the bookmark being moved there.
+UPDATE bookmarks
+SET position = ordered.idx + CASE WHEN ordered.idx >= sqlc.arg(index)::int THEN 1 ELSE 0 END
+FROM (
+    SELECT chirp_id, (ROW_NUMBER() OVER (ORDER BY position, created_at DESC) - 1)::int AS idx
+    FROM bookmarks
+    WHERE user_id = sqlc.arg(user_id)::uuid
+        AND collection_id IS NOT DISTINCT FROM sqlc.narg(collection_id)::uuid
+        AND chirp_id <> sqlc.arg(chirp_id)::uuid
+) AS ordered
+WHERE bookmarks.user_id = sqlc.arg(user_id)::uuid AND bookmarks.chirp_id = ordered.chirp_id;
+
+-- name: MoveBookmark :execrows
+UPDATE bookmarks
+SET collection_id = sqlc.narg(collection_id)::uuid, position = sqlc.arg(index)::int
+WHERE user_id = sqlc.arg(user_id)::uuid AND chirp_id = sqlc.arg(chirp_id)::uuid;
+
+-- name: ListBookmarkedChirps :many
+-- Every bookmark, newest first. Chirps the viewer could no longer open
+-- are left out but keep their bookmark in case they come back.
+SELECT chirps.* FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)::uuid
+    AND chirps.hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.arg(user_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(user_id)::uuid)
+            OR (blocks.blocker_id = sqlc.arg(user_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id <> sqlc.arg(user_id)::uuid
+    )
+ORDER BY bookmarks.created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ListCollectionChirps :many
+-- One collection's bookmarks in their saved order; a NULL collection_id
+-- lists the unfiled ones. Visibility rules match ListBookmarkedChirps.
+SELECT chirps.* FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)::uuid
+    AND bookmarks.collection_id IS NOT DISTINCT FROM sqlc.narg(collection_id)::uuid
+    AND chirps.hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.arg(user_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(user_id)::uuid)
+            OR (blocks.blocker_id = sqlc.arg(user_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id <> sqlc.arg(user_id)::uuid
+    )
+ORDER BY bookmarks.position ASC, bookmarks.created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ListBookmarkedChirpIDs :many
+-- Which of chirp_ids user_id has bookmarked, for the bookmarked flag.
+SELECT chirp_id FROM bookmarks
+WHERE user_id = sqlc.arg(user_id)::uuid AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
@@ -1,22 +1,134 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
+-- Replies across a block in either direction insert nothing, so the caller
+-- sees sql.ErrNoRows.
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
+SELECT
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    sqlc.arg(body)::text,
+    sqlc.arg(user_id)::uuid,
+    sqlc.narg(reply_to_id)::uuid
+WHERE NOT EXISTS (
+    SELECT 1 FROM chirps AS parent
+    JOIN blocks ON (blocks.blocker_id = parent.user_id AND blocks.blocked_id = sqlc.arg(user_id)::uuid)
+        OR (blocks.blocker_id = sqlc.arg(user_id)::uuid AND blocks.blocked_id = parent.user_id)
+    WHERE parent.id = sqlc.narg(reply_to_id)::uuid
 )
 RETURNING *;
 
 -- name: GetChirps :many
+-- viewer_id may be NULL for anonymous callers, in which case only hidden
+-- chirps and those that are shadowed or by shadow-banned or deactivated
+-- authors are filtered. Authors still see their own shadowed chirps.
 SELECT * FROM chirps
+WHERE hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = sqlc.narg(viewer_id)::uuid AND mutes.muted_id = chirps.user_id
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM sqlc.narg(viewer_id)::uuid
+    )
 ORDER BY created_at ASC;
 
+-- name: GetVisibleChirp :one
+-- Like GetChirpByID, but a chirp across a block from viewer_id, or one
+-- that is shadowed or by a shadow-banned or deactivated author other than
+-- viewer_id, is not found.
+-- Muted authors' chirps can still be opened directly.
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id)::uuid
+    AND hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM sqlc.narg(viewer_id)::uuid
+    );
+
 -- name: GetChirpByID :one
 SELECT * FROM chirps
 WHERE id = $1;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: HideChirp :one
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ShadowChirp :exec
+UPDATE chirps
+SET shadowed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnshadowChirp :one
+UPDATE chirps
+SET shadowed_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListRecentChirpsByUser :many
+-- Feeds spam scoring, which only needs enough history to spot repeats.
+SELECT id, body, created_at FROM chirps
+WHERE user_id = sqlc.arg(user_id)::uuid
+    AND created_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+ORDER BY created_at DESC
+LIMIT 200;
+
+-- name: GetDatabaseTime :one
+-- The clock chirp and user timestamps are written with, for comparing
+-- against them.
+SELECT NOW()::timestamp AS now;
+
+-- name: HoldChirp :exec
+INSERT INTO held_chirps (chirp_id, announced, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: RestoreHeldChirp :execrows
+-- Undoes the hold on a chirp spam scoring held back; chirps that weren't
+-- held are left alone.
+UPDATE chirps
+SET hidden_at = NULL, shadowed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND EXISTS (SELECT 1 FROM held_chirps WHERE held_chirps.chirp_id = chirps.id);
+
+-- name: ReleaseHeldChirp :one
+-- Ends the hold on a held chirp once it is visible to everyone.
+DELETE FROM held_chirps
+WHERE chirp_id = $1 AND EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = held_chirps.chirp_id AND chirps.hidden_at IS NULL AND chirps.shadowed_at IS NULL
+)
+RETURNING announced;
+
+-- name: UnhideChirp :one
+UPDATE chirps
+SET hidden_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body, reply_to_id, media)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(body)::text,
+    sqlc.narg(reply_to_id)::uuid,
+    sqlc.arg(media)::jsonb
+)
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE id = $1;
+
+-- name: ListDrafts :many
+SELECT * FROM drafts
+WHERE user_id = sqlc.arg(user_id)::uuid
+ORDER BY updated_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UpdateDraft :one
+-- When if_updated_at is given the update only applies if nobody else
+-- saved the draft since, so two devices can't silently overwrite each
+-- other.
+UPDATE drafts
+SET body = sqlc.arg(body)::text,
+    reply_to_id = sqlc.narg(reply_to_id)::uuid,
+    media = sqlc.arg(media)::jsonb,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)::uuid
+    AND user_id = sqlc.arg(user_id)::uuid
+    AND (sqlc.narg(if_updated_at)::timestamp IS NULL OR updated_at = sqlc.narg(if_updated_at)::timestamp)
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1 AND user_id = $2;
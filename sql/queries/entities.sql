@@ -0,0 +1,88 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, tag, created_at)
+VALUES (gen_random_uuid(), $1, NOW())
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING id;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListChirpsByTag :many
+SELECT chirps.* FROM chirps
+WHERE EXISTS (
+        SELECT 1 FROM chirp_hashtags
+        JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+        WHERE chirp_hashtags.chirp_id = chirps.id AND hashtags.tag = sqlc.arg(tag)::text
+    )
+    AND chirps.hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = sqlc.narg(viewer_id)::uuid AND mutes.muted_id = chirps.user_id
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM sqlc.narg(viewer_id)::uuid
+    )
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: TrendingHashtags :many
+-- A chirp repeating a tag counts once.
+SELECT hashtags.tag, COUNT(DISTINCT chirps.id) AS uses
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.created_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+    AND chirps.hidden_at IS NULL AND chirps.shadowed_at IS NULL
+    AND users.account_state NOT IN ('shadow_banned', 'deactivated')
+GROUP BY hashtags.tag
+ORDER BY uses DESC, hashtags.tag ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListHashtagsForChirps :many
+SELECT chirp_hashtags.chirp_id, hashtags.tag, chirp_hashtags.start_offset, chirp_hashtags.end_offset
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE chirp_hashtags.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_hashtags.chirp_id, chirp_hashtags.start_offset;
+
+-- name: ListVisibleMentionsForChirps :many
+-- Mentions stay live only while the mentioned user is visible: not
+-- deactivated or shadow banned, and on neither side of a block with the
+-- chirp's author or with viewer_id.
+SELECT chirp_mentions.chirp_id, chirp_mentions.start_offset, chirp_mentions.end_offset
+FROM chirp_mentions
+JOIN chirps ON chirps.id = chirp_mentions.chirp_id
+JOIN users ON users.id = chirp_mentions.user_id
+WHERE chirp_mentions.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+    AND (users.account_state NOT IN ('shadow_banned', 'deactivated') OR users.id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = users.id AND blocks.blocked_id = chirps.user_id)
+            OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = users.id)
+            OR (blocks.blocker_id = users.id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = users.id)
+    )
+ORDER BY chirp_mentions.chirp_id, chirp_mentions.start_offset;
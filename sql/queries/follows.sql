@@ -0,0 +1,18 @@
+-- name: FollowUser :execrows
+-- Follows across a block in either direction insert nothing.
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT sqlc.arg(follower_id)::uuid, sqlc.arg(followee_id)::uuid, NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(follower_id)::uuid AND blocked_id = sqlc.arg(followee_id)::uuid)
+        OR (blocker_id = sqlc.arg(followee_id)::uuid AND blocked_id = sqlc.arg(follower_id)::uuid)
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFolloweeIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = $1;
@@ -0,0 +1,49 @@
+-- name: EnqueueLinkPreview :exec
+-- Previews are cached per URL; one that was fetched (or given up on) more
+-- than a day ago is queued again when another chirp links to it.
+INSERT INTO link_previews (url, status, next_attempt_at, created_at)
+VALUES ($1, 'pending', NOW(), NOW())
+ON CONFLICT (url) DO UPDATE
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE link_previews.status <> 'pending'
+  AND link_previews.fetched_at < NOW() - INTERVAL '1 day';
+
+-- name: AddChirpLink :exec
+INSERT INTO chirp_links (chirp_id, url, position)
+VALUES ($1, $2, $3)
+ON CONFLICT (chirp_id, url) DO NOTHING;
+
+-- name: DeleteChirpLinks :exec
+DELETE FROM chirp_links
+WHERE chirp_id = $1;
+
+-- name: ClaimDueLinkPreviews :many
+UPDATE link_previews
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE url IN (
+    SELECT url FROM link_previews
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteLinkPreview :exec
+UPDATE link_previews
+SET status = 'ready', title = $1, description = $2, image_url = $3, site_name = $4,
+    attempts = attempts + 1, last_error = '', fetched_at = NOW()
+WHERE url = $5;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3, fetched_at = NOW()
+WHERE url = $4;
+
+-- name: ListLinkPreviewsForChirps :many
+SELECT chirp_links.chirp_id, link_previews.url, link_previews.title, link_previews.description,
+    link_previews.image_url, link_previews.site_name
+FROM chirp_links
+JOIN link_previews ON link_previews.url = chirp_links.url
+WHERE chirp_links.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]) AND link_previews.status = 'ready'
+ORDER BY chirp_links.chirp_id, chirp_links.position;
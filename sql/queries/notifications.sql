@@ -0,0 +1,62 @@
+-- name: CreateNotification :one
+-- Nothing is inserted when the recipient muted the actor, a block stands
+-- between them or the actor is shadow-banned; the caller sees
+-- sql.ErrNoRows.
+INSERT INTO notifications (id, recipient_id, actor_id, type, chirp_id, created_at)
+SELECT
+    gen_random_uuid(),
+    sqlc.arg(recipient_id)::uuid,
+    sqlc.arg(actor_id)::uuid,
+    sqlc.arg(type)::text,
+    sqlc.narg(chirp_id)::uuid,
+    NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE muter_id = sqlc.arg(recipient_id)::uuid AND muted_id = sqlc.arg(actor_id)::uuid
+) AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(recipient_id)::uuid AND blocked_id = sqlc.arg(actor_id)::uuid)
+        OR (blocker_id = sqlc.arg(actor_id)::uuid AND blocked_id = sqlc.arg(recipient_id)::uuid)
+) AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE id = sqlc.arg(actor_id)::uuid AND account_state = 'shadow_banned'
+)
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE recipient_id = $1
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = notifications.recipient_id AND mutes.muted_id = notifications.actor_id
+    )
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListUnreadNotifications :many
+SELECT * FROM notifications
+WHERE recipient_id = $1 AND read_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = notifications.recipient_id AND mutes.muted_id = notifications.actor_id
+    )
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE recipient_id = $1 AND read_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = notifications.recipient_id AND mutes.muted_id = notifications.actor_id
+    );
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND recipient_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE recipient_id = $1 AND read_at IS NULL;
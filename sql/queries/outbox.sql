@@ -0,0 +1,62 @@
+-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload, created_at, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+-- Pushes next_attempt_at out by the lease so that other relays skip the
+-- claimed events while they are being published.
+UPDATE outbox_events
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE published_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY id
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ListOutboxSinkDeliveries :many
+SELECT sink FROM outbox_sink_deliveries
+WHERE event_id = $1;
+
+-- name: RecordOutboxSinkDelivery :exec
+INSERT INTO outbox_sink_deliveries (event_id, sink, published_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (event_id, sink) DO NOTHING;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET published_at = NOW(), attempts = attempts + 1, last_error = ''
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = NOW() + make_interval(secs => sqlc.arg(backoff_seconds)::float8)
+WHERE id = sqlc.arg(id);
+
+-- name: ListCommittedOutboxEventsAfter :many
+-- Lists events in commit order after the cursor (after_txid, after_id).
+-- Only events whose transaction is older than every transaction still in
+-- progress are returned, so no event can later commit ahead of the cursor.
+SELECT * FROM outbox_events
+WHERE (txid, id) > (sqlc.arg(after_txid)::bigint, sqlc.arg(after_id)::bigint)
+  AND txid < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+  AND event_type = ANY(sqlc.arg(event_types)::text[])
+ORDER BY txid, id
+LIMIT sqlc.arg('limit');
+
+-- name: GetOutboxHorizon :one
+-- The oldest transaction still in progress. Events of that transaction and
+-- later ones have not been listed by ListCommittedOutboxEventsAfter yet.
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint AS txid;
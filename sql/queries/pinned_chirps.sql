@@ -0,0 +1,94 @@
+-- name: LockUserPins :exec
+-- Serializes pin changes for one user so the pin limit holds under
+-- concurrent requests. Call it inside a transaction.
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListPinnedChirpIDs :many
+-- Pins on chirps hidden by moderation are kept in case the chirp is
+-- unhidden, but don't count against the pin limit meanwhile. Unhiding can
+-- leave a user over the limit until they unpin something.
+SELECT pinned_chirps.chirp_id FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1 AND chirps.hidden_at IS NULL
+ORDER BY pinned_chirps.position ASC, pinned_chirps.created_at DESC;
+
+-- name: PinChirp :exec
+-- The new pin goes on top.
+INSERT INTO pinned_chirps (user_id, chirp_id, position, created_at)
+SELECT
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(chirp_id)::uuid,
+    COALESCE(MIN(position), 0) - 1,
+    NOW()
+FROM pinned_chirps
+WHERE user_id = sqlc.arg(user_id)::uuid
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: MakeRoomForPin :exec
+-- Renumbers the user's other pins 0, 1, 2... leaving a gap at index for
+-- the pin being moved there.
+UPDATE pinned_chirps
+SET position = ordered.idx + CASE WHEN ordered.idx >= sqlc.arg(index)::int THEN 1 ELSE 0 END
+FROM (
+    SELECT chirp_id, (ROW_NUMBER() OVER (ORDER BY position, created_at DESC) - 1)::int AS idx
+    FROM pinned_chirps
+    WHERE user_id = sqlc.arg(user_id)::uuid AND chirp_id <> sqlc.arg(chirp_id)::uuid
+) AS ordered
+WHERE pinned_chirps.user_id = sqlc.arg(user_id)::uuid AND pinned_chirps.chirp_id = ordered.chirp_id;
+
+-- name: MovePin :execrows
+UPDATE pinned_chirps
+SET position = sqlc.arg(index)::int
+WHERE user_id = sqlc.arg(user_id)::uuid AND chirp_id = sqlc.arg(chirp_id)::uuid;
+
+-- name: ListPinnedChirps :many
+-- A user's pinned chirps in order, with the same visibility rules as
+-- GetVisibleChirp.
+SELECT chirps.* FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = sqlc.arg(user_id)::uuid
+    AND chirps.hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM sqlc.narg(viewer_id)::uuid
+    )
+ORDER BY pinned_chirps.position ASC, pinned_chirps.created_at DESC;
+
+-- name: ListUserChirps :many
+-- A user's profile listing, newest first, leaving out pinned chirps since
+-- those are listed separately. Muting the user doesn't hide their profile.
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)::uuid
+    AND hidden_at IS NULL
+    AND (chirps.shadowed_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id)::uuid)
+    AND NOT EXISTS (
+        SELECT 1 FROM pinned_chirps
+        WHERE pinned_chirps.user_id = chirps.user_id AND pinned_chirps.chirp_id = chirps.id
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+            OR (blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND blocks.blocked_id = chirps.user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users AS author
+        WHERE author.id = chirps.user_id
+            AND author.account_state IN ('shadow_banned', 'deactivated')
+            AND author.id IS DISTINCT FROM sqlc.narg(viewer_id)::uuid
+    )
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
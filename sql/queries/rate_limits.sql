@@ -0,0 +1,26 @@
+-- name: TakeRateLimitToken :one
+-- Refills the bucket at rate tokens per second up to burst, then takes a
+-- token if one is there. allowed says whether this call got one; a refused
+-- call keeps the refilled balance. The row lock taken by the upsert makes
+-- concurrent takes on one key queue up instead of double spending.
+INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+VALUES (sqlc.arg(key)::text, sqlc.arg(burst)::float8 - 1, TRUE, NOW())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST(sqlc.arg(burst)::float8, rate_limit_buckets.tokens
+            + sqlc.arg(rate)::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8) >= 1
+        THEN LEAST(sqlc.arg(burst)::float8, rate_limit_buckets.tokens
+            + sqlc.arg(rate)::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8) - 1
+        ELSE LEAST(sqlc.arg(burst)::float8, rate_limit_buckets.tokens
+            + sqlc.arg(rate)::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8)
+    END,
+    allowed = LEAST(sqlc.arg(burst)::float8, rate_limit_buckets.tokens
+        + sqlc.arg(rate)::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8) >= 1,
+    updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+-- Buckets untouched for longer than it takes any policy to refill are full
+-- and can be forgotten.
+DELETE FROM rate_limit_buckets
+WHERE updated_at < NOW() - make_interval(secs => sqlc.arg(idle_seconds)::float8);
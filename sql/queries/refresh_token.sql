@@ -15,7 +15,13 @@ RETURNING token;
 SELECT expires_at, revoked_at, user_id FROM refresh_tokens
 WHERE token = $1;
 
--- name: RevokeToken :exec
+-- name: RevokeToken :one
 UPDATE refresh_tokens
 SET revoked_at = NOW() AT TIME ZONE 'UTC', updated_at = NOW() AT TIME ZONE 'UTC'
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1
+RETURNING user_id;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW() AT TIME ZONE 'UTC', updated_at = NOW() AT TIME ZONE 'UTC'
+WHERE user_id = $1 AND revoked_at IS NULL;
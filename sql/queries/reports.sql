@@ -0,0 +1,106 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: CreateSystemReport :one
+-- Returns no rows when the chirp already has a pending report for reason.
+INSERT INTO reports (id, created_at, updated_at, reported_user_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, reason) WHERE state IN ('open', 'claimed') AND reporter_id IS NULL
+DO NOTHING
+RETURNING *;
+
+-- name: UpdatePendingSystemReport :exec
+UPDATE reports
+SET details = $1, updated_at = NOW()
+WHERE chirp_id = $2 AND reason = $3 AND reporter_id IS NULL
+    AND state IN ('open', 'claimed');
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReportQueue :many
+SELECT * FROM reports
+WHERE state = ANY(sqlc.arg(states)::text[])
+ORDER BY created_at
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetReportForUpdate :one
+SELECT * FROM reports
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListPendingSystemReports :many
+SELECT * FROM reports
+WHERE reason = $1 AND reporter_id IS NULL AND state IN ('open', 'claimed')
+ORDER BY created_at
+LIMIT $2 OFFSET $3;
+
+-- name: ClaimReport :one
+UPDATE reports
+SET state = 'claimed', assignee_id = $1, updated_at = NOW()
+WHERE id = $2 AND state = 'open'
+RETURNING *;
+
+-- name: ReleaseReport :one
+UPDATE reports
+SET state = 'open', assignee_id = NULL, updated_at = NOW()
+WHERE id = $1 AND state = 'claimed'
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET state = $1, resolution = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $3 AND state = 'claimed'
+RETURNING *;
+
+-- name: ResolveOpenReportsForChirp :many
+UPDATE reports
+SET state = $1, resolution = $2, assignee_id = $3, resolved_at = NOW(), updated_at = NOW()
+WHERE chirp_id = $4 AND state = 'open'
+RETURNING id;
+
+-- name: CreateReportEvent :exec
+INSERT INTO report_events (report_id, actor_id, from_state, to_state, note, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+
+-- name: ListReportEvents :many
+SELECT * FROM report_events
+WHERE report_id = $1
+ORDER BY id;
+
+-- name: CreateUserWarning :one
+INSERT INTO user_warnings (id, user_id, moderator_id, report_id, reason, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING *;
+
+-- name: ListUserWarnings :many
+SELECT * FROM user_warnings
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,55 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, reply_to_id, media, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(body)::text,
+    sqlc.narg(reply_to_id)::uuid,
+    sqlc.arg(media)::jsonb,
+    sqlc.arg(publish_at)::timestamptz
+)
+RETURNING *;
+
+-- name: GetScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = sqlc.arg(user_id)::uuid AND state = ANY(sqlc.arg(states)::text[])
+ORDER BY publish_at ASC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CancelScheduledChirp :execrows
+UPDATE scheduled_chirps
+SET state = 'canceled', updated_at = NOW()
+WHERE id = $1 AND state = 'pending';
+
+-- name: RescheduleChirp :one
+-- Failed chirps can be rescheduled too, which retries them.
+UPDATE scheduled_chirps
+SET publish_at = sqlc.arg(publish_at)::timestamptz,
+    state = 'pending',
+    last_error = NULL,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)::uuid AND state IN ('pending', 'failed')
+RETURNING *;
+
+-- name: ListDueScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE state = 'pending' AND publish_at <= NOW()
+ORDER BY publish_at ASC
+LIMIT $1;
+
+-- name: MarkScheduledChirpPublished :execrows
+-- Affects nothing when the chirp was canceled or rescheduled meanwhile.
+UPDATE scheduled_chirps
+SET state = 'published', chirp_id = sqlc.arg(chirp_id)::uuid, updated_at = NOW()
+WHERE id = sqlc.arg(id)::uuid AND state = 'pending' AND publish_at <= NOW();
+
+-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET state = 'failed', last_error = $1, updated_at = NOW()
+WHERE id = $2 AND state = 'pending';
@@ -0,0 +1,65 @@
+-- name: UpsertSubscription :one
+-- An upgrade or renewal that doesn't say when the new period ends runs for
+-- default_period_days from now, or keeps the end on record if that is
+-- later. Either way the subscription is no longer legacy.
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, legacy, created_at, updated_at)
+VALUES (
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(plan)::text,
+    sqlc.arg(status)::text,
+    COALESCE(
+        sqlc.narg(current_period_end)::timestamptz,
+        NOW() + make_interval(days => sqlc.arg(default_period_days)::int)
+    ),
+    false,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = CASE
+        WHEN sqlc.narg(current_period_end)::timestamptz IS NULL
+            THEN GREATEST(subscriptions.current_period_end, EXCLUDED.current_period_end)
+        ELSE EXCLUDED.current_period_end
+    END,
+    legacy = false,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET status = $1, updated_at = NOW()
+WHERE user_id = $2
+RETURNING *;
+
+-- name: ExpireSubscription :one
+UPDATE subscriptions
+SET status = 'expired', current_period_end = NOW(), updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: IsUserChirpyRed :one
+-- A subscription keeps its benefits until the paid period runs out, even
+-- when payment failed or it was canceled. Active legacy subscriptions have
+-- no period and stay Red until they are downgraded.
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = $1
+      AND (
+        (status = 'active' AND legacy AND current_period_end IS NULL)
+        OR (status IN ('active', 'past_due', 'canceled') AND current_period_end > NOW())
+      )
+) AS is_chirpy_red;
+
+-- name: GetActivePlan :one
+SELECT plan FROM subscriptions
+WHERE user_id = $1
+  AND (
+    (status = 'active' AND legacy AND current_period_end IS NULL)
+    OR (status IN ('active', 'past_due', 'canceled') AND current_period_end > NOW())
+  );
\ No newline at end of file
@@ -1,13 +1,14 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    sqlc.arg(email)::text,
+    sqlc.arg(hashed_password)::text,
+    sqlc.narg(handle)::text
 )
-RETURNING id, created_at, updated_at, email;
+RETURNING id, created_at, updated_at, email, handle;
 
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
@@ -22,7 +23,66 @@ SET email = $1, hashed_password = $2
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUser :exec
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, role, handle, account_state, suspended_until,
+    EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE subscriptions.user_id = users.id
+          AND (
+            (subscriptions.status = 'active' AND subscriptions.legacy AND subscriptions.current_period_end IS NULL)
+            OR (subscriptions.status IN ('active', 'past_due', 'canceled') AND subscriptions.current_period_end > NOW())
+          )
+    ) AS is_chirpy_red
+FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE email = $2;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserHandle :one
 UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+SET handle = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: GetUsersByHandles :many
+SELECT * FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[]);
+
+-- name: GetMentionableUsersByHandles :many
+-- Users on either side of a block with author_id can't be mentioned by
+-- them.
+SELECT * FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[])
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = users.id AND blocks.blocked_id = sqlc.arg(author_id)::uuid)
+            OR (blocks.blocker_id = sqlc.arg(author_id)::uuid AND blocks.blocked_id = users.id)
+    );
+
+-- name: SetAccountState :one
+-- suspended_until only means something for the suspended state and is
+-- cleared otherwise.
+UPDATE users
+SET account_state = sqlc.arg(account_state)::text,
+    suspended_until = sqlc.narg(suspended_until)::timestamptz,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)::uuid
+RETURNING *;
@@ -0,0 +1,45 @@
+-- name: EnqueueWebhookDeliveries :execrows
+-- Fans an event out to every active endpoint subscribed to it. Endpoints
+-- without an owner are admin endpoints and receive events for all users.
+-- Fanning out the same outbox event twice adds nothing.
+INSERT INTO webhook_deliveries (id, endpoint_id, outbox_event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+SELECT gen_random_uuid(), id, sqlc.arg(outbox_event_id)::bigint, sqlc.arg(event_type)::text, sqlc.arg(payload)::jsonb, 'pending', 0, NOW(), NOW()
+FROM webhook_endpoints
+WHERE active
+  AND sqlc.arg(event_type)::text = ANY(events)
+  AND (owner_id IS NULL OR owner_id = sqlc.arg(subject_user_id)::uuid)
+ON CONFLICT (endpoint_id, outbox_event_id) DO NOTHING;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Pushes next_attempt_at out by the lease so that other dispatchers skip
+-- the claimed rows while they are being delivered.
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $1, last_error = '', delivered_at = NOW()
+WHERE id = $2;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status)::text,
+    attempts = attempts + 1,
+    next_attempt_at = NOW() + make_interval(secs => sqlc.arg(retry_seconds)::float8),
+    last_status_code = sqlc.narg(last_status_code)::int,
+    last_error = sqlc.arg(last_error)::text
+WHERE id = sqlc.arg(id)::uuid;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
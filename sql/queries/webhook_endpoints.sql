@@ -0,0 +1,31 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, owner_id, url, secret, events, active, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    true,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpointsForOwner :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id = sqlc.arg(owner_id)::uuid
+ORDER BY created_at ASC;
+
+-- name: ListGlobalWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id IS NULL
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1;
@@ -0,0 +1,48 @@
+-- name: InsertWebhookEvent :one
+INSERT INTO webhook_events (id, source, event, payload, status, received_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    'received',
+    NOW(),
+    NOW()
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ClaimWebhookEvent :one
+-- Marks an event processing so concurrent deliveries of it don't apply it
+-- twice. Only new and failed events can be claimed, plus finished ones
+-- when replaying; an attempt that has been processing for longer than
+-- five minutes is presumed dead and can be taken over.
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+WHERE id = sqlc.arg(id)::text
+    AND (
+        status IN ('received', 'failed')
+        OR (sqlc.arg(replay)::boolean AND status IN ('processed', 'ignored'))
+        OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes')
+    )
+RETURNING *;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = $1, last_error = '', processed_at = NOW(), updated_at = NOW()
+WHERE id = $2;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', last_error = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY received_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, created_at, updated_at)
+SELECT id, 'red', 'active', NULL, NOW(), NOW()
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN DEFAULT false;
+
+UPDATE users
+SET is_chirpy_red = true
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status IN ('active', 'past_due', 'canceled')
+      AND (current_period_end IS NULL OR current_period_end > NOW())
+);
+
+DROP TABLE subscriptions;
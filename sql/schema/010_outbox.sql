@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_type TEXT NOT NULL,
+    aggregate_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    -- The writing transaction. IDs are handed out before commit, so they
+    -- can commit out of order; streams order events by (txid, id) instead.
+    txid BIGINT NOT NULL DEFAULT pg_current_xact_id()::text::bigint
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (next_attempt_at, id) WHERE published_at IS NULL;
+CREATE INDEX outbox_events_txid_idx ON outbox_events (txid, id);
+
+CREATE TABLE outbox_sink_deliveries (
+    event_id BIGINT NOT NULL,
+    sink TEXT NOT NULL,
+    published_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, sink),
+    CONSTRAINT fk_event_id
+        FOREIGN KEY (event_id)
+        REFERENCES outbox_events(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE outbox_sink_deliveries;
+DROP TABLE outbox_events;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id),
+    CONSTRAINT fk_follower_id
+        FOREIGN KEY (follower_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_followee_id
+        FOREIGN KEY (followee_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX follows_followee_idx ON follows (followee_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_outbox_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('chirpy_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER outbox_events_notify
+AFTER INSERT ON outbox_events
+FOR EACH ROW EXECUTE FUNCTION notify_outbox_event();
+
+-- +goose Down
+DROP TRIGGER outbox_events_notify ON outbox_events;
+DROP FUNCTION notify_outbox_event();
+DROP TABLE follows;
@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT UNIQUE;
+ALTER TABLE chirps ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE likes (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    recipient_id UUID NOT NULL,
+    actor_id UUID NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('mention', 'reply', 'like', 'follow')),
+    chirp_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP,
+    CONSTRAINT fk_recipient_id
+        FOREIGN KEY (recipient_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_actor_id
+        FOREIGN KEY (actor_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_recipient_idx ON notifications (recipient_id, created_at DESC);
+CREATE INDEX notifications_unread_idx ON notifications (recipient_id) WHERE read_at IS NULL;
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE likes;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
+ALTER TABLE users DROP COLUMN handle;
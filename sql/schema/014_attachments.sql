@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    chirp_id UUID,
+    position INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_owner_id
+        FOREIGN KEY (owner_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX attachments_chirp_idx ON attachments (chirp_id, position);
+CREATE INDEX attachments_unattached_idx ON attachments (created_at) WHERE chirp_id IS NULL;
+
+-- Blobs whose rows are gone, queued in the same transaction that removed
+-- the rows and deleted from the store in the background until it works.
+CREATE TABLE blob_deletions (
+    key TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX blob_deletions_due_idx ON blob_deletions (next_attempt_at);
+
+-- +goose Down
+DROP TABLE blob_deletions;
+DROP TABLE attachments;
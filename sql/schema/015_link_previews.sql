@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    fetched_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX link_previews_due_idx ON link_previews (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE chirp_links (
+    chirp_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, url),
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE,
+    CONSTRAINT fk_url
+        FOREIGN KEY (url)
+        REFERENCES link_previews(url) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_links;
+DROP TABLE link_previews;
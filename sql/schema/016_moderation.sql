@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    matched_words TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_flags_unreviewed_idx ON chirp_flags (created_at)
+    WHERE reviewed_at IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_words;
@@ -0,0 +1,120 @@
+-- +goose Up
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- reporter_id is NULL for reports raised by the word filter.
+    reporter_id UUID,
+    reported_user_id UUID NOT NULL,
+    chirp_id UUID,
+    reason TEXT NOT NULL CHECK (reason IN (
+        'spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm',
+        'impersonation', 'filtered_language', 'other'
+    )),
+    details TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL DEFAULT 'open'
+        CHECK (state IN ('open', 'claimed', 'resolved', 'dismissed')),
+    assignee_id UUID,
+    resolution TEXT CHECK (resolution IN ('hide_chirp', 'warn', 'suspend', 'dismiss')),
+    resolved_at TIMESTAMP,
+    CONSTRAINT fk_reporter_id
+        FOREIGN KEY (reporter_id)
+        REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_reported_user_id
+        FOREIGN KEY (reported_user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE SET NULL,
+    CONSTRAINT fk_assignee_id
+        FOREIGN KEY (assignee_id)
+        REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX reports_queue_idx ON reports (created_at)
+    WHERE state IN ('open', 'claimed');
+
+CREATE INDEX reports_chirp_idx ON reports (chirp_id);
+
+-- A user can have one pending report per chirp, or per user when no chirp
+-- is involved.
+CREATE UNIQUE INDEX reports_pending_chirp_idx ON reports (reporter_id, chirp_id)
+    WHERE state IN ('open', 'claimed') AND chirp_id IS NOT NULL;
+
+CREATE UNIQUE INDEX reports_pending_user_idx ON reports (reporter_id, reported_user_id)
+    WHERE state IN ('open', 'claimed') AND chirp_id IS NULL;
+
+-- Reports raised by the system have no reporter, so they are deduplicated
+-- per chirp and reason instead.
+CREATE UNIQUE INDEX reports_pending_system_idx ON reports (chirp_id, reason)
+    WHERE state IN ('open', 'claimed') AND reporter_id IS NULL;
+
+CREATE TABLE report_events (
+    id BIGSERIAL PRIMARY KEY,
+    report_id UUID NOT NULL,
+    actor_id UUID,
+    from_state TEXT,
+    to_state TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_report_id
+        FOREIGN KEY (report_id)
+        REFERENCES reports(id) ON DELETE CASCADE,
+    CONSTRAINT fk_actor_id
+        FOREIGN KEY (actor_id)
+        REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX report_events_report_idx ON report_events (report_id, id);
+
+CREATE TABLE user_warnings (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    moderator_id UUID,
+    report_id UUID,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_moderator_id
+        FOREIGN KEY (moderator_id)
+        REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_report_id
+        FOREIGN KEY (report_id)
+        REFERENCES reports(id) ON DELETE SET NULL
+);
+
+CREATE INDEX user_warnings_user_idx ON user_warnings (user_id, created_at DESC);
+
+-- Chirps the word filter flagged join the same queue as user reports.
+INSERT INTO reports (id, created_at, updated_at, reported_user_id, chirp_id, reason, details)
+SELECT chirp_flags.id, chirp_flags.created_at, chirp_flags.created_at, chirps.user_id, chirps.id,
+    'filtered_language', 'matched: ' || array_to_string(chirp_flags.matched_words, ', ')
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirp_flags.reviewed_at IS NULL;
+
+INSERT INTO report_events (report_id, to_state, created_at)
+SELECT id, 'open', created_at FROM reports;
+
+DROP TABLE chirp_flags;
+
+-- +goose Down
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    matched_words TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_flags_unreviewed_idx ON chirp_flags (created_at)
+    WHERE reviewed_at IS NULL;
+
+DROP TABLE user_warnings;
+DROP TABLE report_events;
+DROP TABLE reports;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- suspended_until is the expiry of a suspension; once it passes the account
+-- counts as active again without anything having to rewrite the state.
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP,
+ADD COLUMN account_state TEXT NOT NULL DEFAULT 'active'
+    CHECK (account_state IN ('active', 'suspended', 'shadow_banned', 'deactivated'));
+
+CREATE INDEX users_restricted_state_idx ON users (id) WHERE account_state IN ('shadow_banned', 'deactivated');
+
+-- +goose Down
+DROP INDEX users_restricted_state_idx;
+ALTER TABLE users
+DROP COLUMN account_state,
+DROP COLUMN suspended_until;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- A shadowed chirp was scored as likely spam: it stays visible to its
+-- author and nobody else.
+ALTER TABLE chirps
+ADD COLUMN shadowed_at TIMESTAMP;
+
+CREATE INDEX chirps_user_created_idx ON chirps (user_id, created_at DESC);
+
+-- Chirps spam scoring hid or shadowed. A moderator releasing one restores
+-- it, and announces it then if it was held before it was ever announced.
+CREATE TABLE held_chirps (
+    chirp_id UUID PRIMARY KEY,
+    announced BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE held_chirps;
+DROP INDEX chirps_user_created_idx;
+ALTER TABLE chirps
+DROP COLUMN shadowed_at;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Chirps waiting for their publish_at. They only become rows in chirps
+-- when the publisher posts them, so nothing that reads chirps sees them
+-- early. media holds the attachments to make, as the API received them.
+-- publish_at is an instant from the client, so it keeps its time zone.
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    reply_to_id UUID,
+    media JSONB NOT NULL DEFAULT '[]',
+    publish_at TIMESTAMPTZ NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CHECK (state IN ('pending', 'published', 'canceled', 'failed')),
+    chirp_id UUID,
+    last_error TEXT,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX scheduled_chirps_due_idx ON scheduled_chirps (publish_at)
+    WHERE state = 'pending';
+
+CREATE INDEX scheduled_chirps_user_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Unfinished chirps, synced between a user's devices. Nothing about a
+-- draft is checked until it's published, except that it fits in a row.
+CREATE TABLE drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    reply_to_id UUID,
+    media JSONB NOT NULL DEFAULT '[]',
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX drafts_user_idx ON drafts (user_id, updated_at DESC);
+
+-- +goose Down
+DROP TABLE drafts;
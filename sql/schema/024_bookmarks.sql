@@ -0,0 +1,43 @@
+-- +goose Up
+CREATE TABLE bookmark_collections (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX bookmark_collections_name_idx ON bookmark_collections (user_id, lower(name));
+
+-- A bookmark sits in at most one collection; collection_id is NULL for
+-- unfiled ones. position orders bookmarks within their collection, lowest
+-- first, and new bookmarks go on top. Deleting a chirp removes its
+-- bookmarks; deleting a collection unfiles them.
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    collection_id UUID,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE,
+    CONSTRAINT fk_collection_id
+        FOREIGN KEY (collection_id)
+        REFERENCES bookmark_collections(id) ON DELETE SET NULL
+);
+
+CREATE INDEX bookmarks_collection_idx ON bookmarks (user_id, collection_id, position);
+CREATE INDEX bookmarks_created_idx ON bookmarks (user_id, created_at DESC);
+CREATE INDEX bookmarks_chirp_idx ON bookmarks (chirp_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_collections;
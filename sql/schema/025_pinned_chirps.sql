@@ -0,0 +1,20 @@
+-- +goose Up
+-- position orders a user's pins, lowest first. Deleting a chirp unpins it.
+CREATE TABLE pinned_chirps (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp_id
+        FOREIGN KEY (chirp_id)
+        REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX pinned_chirps_chirp_idx ON pinned_chirps (chirp_id);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Not every target has a UUID: webhook events are keyed by the sender's
+-- event ID.
+ALTER TABLE admin_actions
+ALTER COLUMN target_id TYPE TEXT;
+
+-- +goose Down
+DELETE FROM admin_actions
+WHERE target_type = 'webhook_event';
+
+ALTER TABLE admin_actions
+ALTER COLUMN target_id TYPE UUID USING target_id::uuid;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Subscriptions moved over from is_chirpy_red have no paid period and are
+-- marked legacy; they stay Red until downgraded. Every other subscription
+-- must have a period end.
+ALTER TABLE subscriptions
+ALTER COLUMN current_period_end TYPE TIMESTAMPTZ USING current_period_end AT TIME ZONE 'UTC',
+ADD COLUMN legacy BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE subscriptions
+SET legacy = true
+WHERE current_period_end IS NULL;
+
+ALTER TABLE subscriptions
+ADD CONSTRAINT subscriptions_period_end_check
+    CHECK (legacy OR current_period_end IS NOT NULL);
+
+-- +goose Down
+ALTER TABLE subscriptions
+DROP CONSTRAINT subscriptions_period_end_check,
+DROP COLUMN legacy,
+ALTER COLUMN current_period_end TYPE TIMESTAMP USING current_period_end AT TIME ZONE 'UTC';
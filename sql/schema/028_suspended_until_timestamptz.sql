@@ -0,0 +1,9 @@
+-- +goose Up
+-- suspended_until is compared against the current instant; without a zone
+-- the comparison depended on the server's TimeZone setting.
+ALTER TABLE users
+ALTER COLUMN suspended_until TYPE TIMESTAMPTZ USING suspended_until AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE users
+ALTER COLUMN suspended_until TYPE TIMESTAMP USING suspended_until AT TIME ZONE 'UTC';
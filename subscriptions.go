@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/database"
+	"github.com/jcfullmer/chirpy/internal/entitlements"
+	"github.com/jcfullmer/chirpy/internal/outbox"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
+)
+
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+	defaultPlan          = entitlements.RedPlan
+	// defaultPeriodDays is how long an upgrade or renewal lasts when Polka
+	// doesn't send a period end, so a missing date never grants Red for
+	// good.
+	defaultPeriodDays = 30
+)
+
+type Subscription struct {
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func subscriptionFromDB(s database.Subscription) Subscription {
+	sub := Subscription{
+		Plan:      s.Plan,
+		Status:    s.Status,
+		UpdatedAt: s.UpdatedAt,
+	}
+	if s.CurrentPeriodEnd.Valid {
+		sub.CurrentPeriodEnd = &s.CurrentPeriodEnd.Time
+	}
+	return sub
+}
+
+func (cfg *apiConfig) isChirpyRed(userID uuid.UUID) bool {
+	red, err := cfg.db.IsUserChirpyRed(context.Background(), userID)
+	if err != nil {
+		log.Printf("error checking subscription for %s: %s", userID, err)
+		return false
+	}
+	return red
+}
+
+// applySubscriptionEvent moves a user's subscription through its lifecycle.
+// It reports false for events it does not understand.
+func (cfg *apiConfig) applySubscriptionEvent(event string, userID uuid.UUID, plan string, periodEnd *time.Time) (bool, error) {
+	ctx := context.Background()
+	end := sql.NullTime{}
+	if periodEnd != nil {
+		end = sql.NullTime{Time: periodEnd.UTC(), Valid: true}
+	}
+	switch event {
+	case "user.upgraded", "user.renewed", "user.payment_failed", "user.cancelled", "user.canceled", "user.downgraded":
+	default:
+		return false, nil
+	}
+
+	var sub database.Subscription
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		var err error
+		switch event {
+		case "user.upgraded", "user.renewed":
+			if _, err := q.GetUserByID(ctx, userID); err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %s", errWebhookUserNotFound, userID)
+			} else if err != nil {
+				return err
+			}
+			if plan == "" {
+				plan = defaultPlan
+			}
+			sub, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+				UserID:            userID,
+				Plan:              plan,
+				Status:            subscriptionActive,
+				CurrentPeriodEnd:  end,
+				DefaultPeriodDays: defaultPeriodDays,
+			})
+		case "user.payment_failed":
+			sub, err = q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+				Status: subscriptionPastDue,
+				UserID: userID,
+			})
+		case "user.cancelled", "user.canceled":
+			// Canceling keeps benefits until the end of the paid period; with no
+			// period on record there is nothing left to honour.
+			sub, err = q.GetSubscription(ctx, userID)
+			if err != nil {
+				break
+			}
+			if sub.CurrentPeriodEnd.Valid && sub.CurrentPeriodEnd.Time.After(time.Now()) {
+				sub, err = q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+					Status: subscriptionCanceled,
+					UserID: userID,
+				})
+			} else {
+				sub, err = q.ExpireSubscription(ctx, userID)
+			}
+		case "user.downgraded":
+			sub, err = q.ExpireSubscription(ctx, userID)
+		}
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: no subscription for %s", errWebhookUserNotFound, userID)
+		} else if err != nil {
+			return err
+		}
+		eventType := "subscription.updated"
+		if event == "user.upgraded" {
+			eventType = webhooks.EventUserUpgraded
+		}
+		_, err = outbox.Write(ctx, q, "user", userID, eventType, map[string]any{
+			"user_id":      userID,
+			"event":        event,
+			"subscription": subscriptionFromDB(sub),
+		})
+		return err
+	})
+	if err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (cfg *apiConfig) handleGetSubscription(w http.ResponseWriter, r *http.Request, user database.User) {
+	sub, err := cfg.db.GetSubscription(context.Background(), user.ID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "no subscription", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error loading subscription", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, subscriptionFromDB(sub))
+}
+
+func (cfg *apiConfig) planFor(userID uuid.UUID) string {
+	plan, err := cfg.db.GetActivePlan(context.Background(), userID)
+	if err == sql.ErrNoRows {
+		return entitlements.FreePlan
+	} else if err != nil {
+		log.Printf("error loading plan for %s: %s", userID, err)
+		return entitlements.FreePlan
+	}
+	return plan
+}
+
+func (cfg *apiConfig) entitlementsFor(userID uuid.UUID) entitlements.Entitlements {
+	return cfg.entitlements.For(cfg.planFor(userID))
+}
+
+func (cfg *apiConfig) handleGetEntitlements(w http.ResponseWriter, r *http.Request, user database.User) {
+	type response struct {
+		Plan string `json:"plan"`
+		entitlements.Entitlements
+	}
+	plan := cfg.planFor(user.ID)
+	respondWithJSON(w, http.StatusOK, response{
+		Plan:         plan,
+		Entitlements: cfg.entitlements.For(plan),
+	})
+}
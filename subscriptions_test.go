@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jcfullmer/chirpy/internal/webhooks"
+)
+
+var subscriptionColumns = []string{"user_id", "plan", "status", "current_period_end", "created_at", "updated_at", "legacy"}
+
+func TestUpgradeWithoutPeriodEndGetsDefaultPeriod(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	userID := uuid.New()
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM users").WithArgs(userID).WillReturnRows(userRow(userID))
+	// No period end from Polka, so the query falls back to the default
+	// period instead of storing NULL.
+	mock.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(userID, defaultPlan, subscriptionActive, nil, int32(defaultPeriodDays)).
+		WillReturnRows(sqlmock.NewRows(subscriptionColumns).
+			AddRow(userID, defaultPlan, subscriptionActive, now.AddDate(0, 0, defaultPeriodDays), now, now, false))
+	mock.ExpectQuery("INSERT INTO outbox_events").
+		WithArgs("user", userID, webhooks.EventUserUpgraded, sqlmock.AnyArg()).
+		WillReturnRows(outboxRow(1, webhooks.EventUserUpgraded))
+	mock.ExpectCommit()
+
+	handled, err := cfg.applySubscriptionEvent("user.upgraded", userID, "", nil)
+	if err != nil || !handled {
+		t.Fatalf("applySubscriptionEvent = %v, %v; want true, nil", handled, err)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jcfullmer/chirpy/internal/database"
+)
+
+// withTx runs fn with queries bound to a single transaction, committing if
+// fn succeeds and rolling back otherwise.
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
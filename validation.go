@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	errChirpEmpty    = errors.New("chirp body is empty")
+	errChirpTooLong  = errors.New("chirp is too long")
+	errChirpRejected = errors.New("chirp contains language that isn't allowed")
+	errChirpSpam     = errors.New("chirp looks like spam")
+	errInvalidMedia  = errors.New("invalid media")
+	errInvalidReport = errors.New("invalid report")
+)
+
+// FieldError describes one invalid request field. It wraps a sentinel
+// error so callers can test for the cause with errors.Is.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	err     error
+}
+
+func newFieldError(field, code string, err error, message string) *FieldError {
+	return &FieldError{Field: field, Code: code, Message: message, err: err}
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// ValidationError collects every problem with a request so clients can
+// show them all at once instead of fixing one field per round trip.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		errs = append(errs, f)
+	}
+	return errs
+}
+
+// validationErrors combines the field errors among errs into a
+// *ValidationError, returning nil when there are none.
+func validationErrors(errs ...*FieldError) error {
+	v := &ValidationError{}
+	for _, err := range errs {
+		if err != nil {
+			v.Fields = append(v.Fields, err)
+		}
+	}
+	if len(v.Fields) == 0 {
+		return nil
+	}
+	return v
+}
+
+func respondWithValidationError(w http.ResponseWriter, err error) {
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		var f *FieldError
+		if !errors.As(err, &f) {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		v = &ValidationError{Fields: []*FieldError{f}}
+	}
+	type errorResponse struct {
+		Error  string        `json:"error"`
+		Fields []*FieldError `json:"fields"`
+	}
+	respondWithJSON(w, http.StatusBadRequest, errorResponse{
+		Error:  v.Fields[0].Message,
+		Fields: v.Fields,
+	})
+}